@@ -6,4 +6,4 @@ make dependencies
 make target=judge build
 make target=manager build
 
-docker build -f ./manifests/runner.Dockerfile -t runner:v0.0.7 .
+docker build -f ./manifests/runner.Dockerfile -t runner:v0.0.9 .
@@ -28,7 +28,9 @@ export class ProfileDetailComponent implements OnInit {
 
   stats: GetStatsResponse.AsObject = {
     solvedQuestions: 0,
-    triedQuestions: 0
+    triedQuestions: 0,
+    score: 0,
+    maxScore: 0
   };
   role!: Role;
   canChange: boolean = false;
@@ -1,7 +1,9 @@
 import { Component } from '@angular/core';
 import { ManagerService } from '../../../services/manager.service';
 import {
+  ComparisonMode,
   ID,
+  InputMode,
   Limitations,
   Question,
   QuestionState
@@ -20,12 +22,23 @@ export class NewQuestionComponent {
     statement: '',
     limitations: {
       duration: 0,
-      memory: 0
+      memory: 0,
+      output: 0
     },
     input: '',
     output: '',
     owner: '',
-    state: QuestionState.QUESTION_STATE_DRAFT
+    state: QuestionState.QUESTION_STATE_DRAFT,
+    testCasesList: [],
+    inputMode: InputMode.INPUT_MODE_STDIN,
+    checker: '',
+    checkerLanguage: '',
+    comparisonMode: ComparisonMode.COMPARISON_MODE_EXACT,
+    absoluteEpsilon: 0,
+    relativeEpsilon: 0,
+    subtasksList: [],
+    interactor: '',
+    interactorLanguage: ''
   };
 
   question!: any;
@@ -41,7 +54,8 @@ export class NewQuestionComponent {
       statement: this.question.statement,
       limitations: this.manager.create(new Limitations(), {
         duration: this.question.limitation.duration,
-        memory: this.question.limitation.memory
+        memory: this.question.limitation.memory,
+        output: this.question.limitation.output
       }),
       input: this.question.input,
       output: this.question.output,
@@ -1,6 +1,11 @@
-import { Component, Input } from '@angular/core';
+import { Component, Input, OnInit } from '@angular/core';
 import { ManagerService } from '../../../services/manager.service';
-import { Submission, SubmitRequest } from '../../../services/proto/services_pb';
+import {
+  Empty,
+  Language,
+  Submission,
+  SubmitRequest
+} from '../../../services/proto/services_pb';
 import { ErrorHandlerService } from '../../../services/error-handler.service';
 
 @Component({
@@ -9,9 +14,11 @@ import { ErrorHandlerService } from '../../../services/error-handler.service';
   templateUrl: './submit.component.html',
   styleUrl: './submit.component.css'
 })
-export class SubmitComponent {
+export class SubmitComponent implements OnInit {
   codeInput: string = '';
   file?: File;
+  language: string = 'go';
+  languages: Language.AsObject[] = [];
 
   @Input({ required: true })
   question!: string;
@@ -21,6 +28,17 @@ export class SubmitComponent {
     private readonly manager: ManagerService
   ) {}
 
+  ngOnInit(): void {
+    this.manager
+      .getLanguages(new Empty(), this.manager.getToken())
+      .then(res => {
+        this.languages = res.getLanguagesList().map(value => value.toObject());
+      })
+      .catch(err => {
+        this.errHandler.handleError(err);
+      });
+  }
+
   onSubmit(): void {
     if (this.file) {
       const reader = new FileReader();
@@ -39,7 +57,8 @@ export class SubmitComponent {
         this.manager.create(new SubmitRequest(), {
           submission: this.manager.create(new Submission(), {
             questionId: this.question,
-            code: codeData
+            code: codeData,
+            language: this.language
           })
         })
       )
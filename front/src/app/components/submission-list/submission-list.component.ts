@@ -27,7 +27,13 @@ export class SubmissionListComponent {
       'Memory Limit Exceeded',
     [SubmissionState.SUBMISSION_STATE_TIME_LIMIT_EXCEEDED]:
       'Time Limit Exceeded',
-    [SubmissionState.SUBMISSION_STATE_RUNTIME_ERROR]: 'Runtime Error'
+    [SubmissionState.SUBMISSION_STATE_RUNTIME_ERROR]: 'Runtime Error',
+    [SubmissionState.SUBMISSION_STATE_FAILED]: 'Failed',
+    [SubmissionState.SUBMISSION_STATE_IDLENESS_LIMIT_EXCEEDED]:
+      'Idleness Limit Exceeded',
+    [SubmissionState.SUBMISSION_STATE_PROTOCOL_VIOLATION]: 'Protocol Violation',
+    [SubmissionState.SUBMISSION_STATE_OUTPUT_LIMIT_EXCEEDED]:
+      'Output Limit Exceeded'
   };
 
   submissions!: Submission.AsObject[];
@@ -72,6 +78,16 @@ export class SubmissionListComponent {
           val.stateTitle = this.stateTitles[
             value.hasState() ? value.getState() : 0
           ];
+          val.timeTitle = value.hasWallTime()
+            ? `${value.getWallTime()} ms`
+            : '';
+          val.memoryTitle = value.hasMemory() ? `${value.getMemory()} KB` : '';
+          val.compileTimeTitle = value.hasCompileTime()
+            ? `${value.getCompileTime()} ms`
+            : '';
+          val.scoreTitle = value.hasScore()
+            ? `${value.getScore()} / ${value.getMaxScore()}`
+            : '';
           return val as Submission.AsObject;
         });
         this.totalPageCount = res.getTotalPageSize();
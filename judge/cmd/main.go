@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
+	"fmt"
 	"github.com/CT1403-2/Code-Judgement/judge/internal/runner"
 	"github.com/CT1403-2/Code-Judgement/proto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"log"
 	"os"
@@ -17,6 +21,9 @@ import (
 )
 
 func main() {
+	// the process runner starts its sandboxes as this binary
+	runner.Init()
+
 	configPath := flag.String("config", "", "path to config file")
 	flag.Parse()
 
@@ -32,17 +39,56 @@ func main() {
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		sig := <-sigCh
-		log.Printf("Received signal: %v", sig)
+		log.Printf("Received signal: %v, draining submissions", sig)
 		cancel()
+		sig = <-sigCh
+		log.Fatalf("Received signal: %v, exiting without draining", sig)
 	}()
 
 	runnerInstance := runner.New(cfg)
 	ctrl := controller.New(cfg, func(c *config.Config) (proto.ManagerClient, error) {
-		conn, err := grpc.NewClient(c.Manager.Address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		creds, err := transportCredentials(c.Manager.TLS)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := grpc.NewClient(c.Manager.Address, grpc.WithTransportCredentials(creds))
 		if err != nil {
 			return nil, err
 		}
 		return proto.NewManagerClient(conn), nil
 	}, runnerInstance)
 	ctrl.Run(ctx)
+
+	if err := runnerInstance.Close(); err != nil {
+		log.Printf("Failed to close runner: %v", err)
+	}
+}
+
+func transportCredentials(cfg config.TLSConfig) (credentials.TransportCredentials, error) {
+	if !cfg.Enabled {
+		return insecure.NewCredentials(), nil
+	}
+	tlsConfig := &tls.Config{
+		ServerName: cfg.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %v", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(tlsConfig), nil
 }
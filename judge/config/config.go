@@ -3,30 +3,185 @@ package config
 import (
 	"errors"
 	"fmt"
+	"os"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
+const leaseDuration = 30 * time.Second // as leased by the manager
+
 type Config struct {
-	Manager ManagerConfig `mapstructure:"manager"`
-	Runner  RunnerConfig  `mapstructure:"runner"`
+	Name                string        `mapstructure:"name"` // identifies the judge in leases, defaults to the hostname
+	Manager             ManagerConfig `mapstructure:"manager"`
+	Runner              RunnerConfig  `mapstructure:"runner"`
+	ShutdownGracePeriod time.Duration `mapstructure:"shutdown_grace_period"` // before releasing submissions on exit
 }
 
 type ManagerConfig struct {
-	Address string        `mapstructure:"address"`
-	Timeout time.Duration `mapstructure:"timeout"`
+	Address           string        `mapstructure:"address"`
+	Timeout           time.Duration `mapstructure:"timeout"`
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"` // well below leaseDuration
+	APIKey            string        `mapstructure:"api_key"`            // issued for Name, prefer JUDGE_MANAGER_API_KEY
+	TLS               TLSConfig     `mapstructure:"tls"`
+}
+
+type TLSConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	CAFile     string `mapstructure:"ca_file"`     // verifies the manager, the system roots when empty
+	ServerName string `mapstructure:"server_name"` // defaults to the host of the manager address
+	CertFile   string `mapstructure:"cert_file"`   // with KeyFile, for mutual TLS
+	KeyFile    string `mapstructure:"key_file"`
 }
 
+// Runners a judge can run submissions with.
+const (
+	DockerRunner  = "docker"
+	ProcessRunner = "process"
+)
+
 type RunnerConfig struct {
-	Image string `mapstructure:"image"`
+	Type      string                    `mapstructure:"type"`
+	Image     string                    `mapstructure:"image"`
+	Languages map[string]LanguageConfig `mapstructure:"languages"`
+	Workers   int                       `mapstructure:"workers"`   // submissions judged at the same time
+	CPUs      []string                  `mapstructure:"cpus"`      // cpusets of the workers, e.g. ["2-3", "4-5"]
+	PoolSize  int                       `mapstructure:"pool_size"` // containers reused per image and cpuset
+	Process   ProcessConfig             `mapstructure:"process"`
+}
+
+type ProcessConfig struct {
+	WorkDir       string   `mapstructure:"work_dir"`
+	Cgroup        string   `mapstructure:"cgroup"`    // a cgroup v2 directory delegated to the judge
+	FirstUID      int      `mapstructure:"first_uid"` // of UIDs ids unused on the host, two per run
+	UIDs          int      `mapstructure:"uids"`
+	ReadOnlyPaths []string `mapstructure:"read_only_paths"` // globs of the toolchains programs see
+}
+
+// DefaultReadOnlyPaths hold the toolchains of DefaultLanguages as Debian installs them.
+var DefaultReadOnlyPaths = []string{
+	"/bin", "/lib", "/lib32", "/lib64", "/usr",
+	"/etc/alternatives", "/etc/ld.so.cache", "/etc/java-*",
+}
+
+// LanguageConfig describes how submissions of a language are built and run, from the directory of SourceFile.
+type LanguageConfig struct {
+	SourceFile         string        `mapstructure:"source_file"`
+	CompileCommand     string        `mapstructure:"compile_command"` // empty for interpreted languages
+	RunCommand         string        `mapstructure:"run_command"`
+	Image              string        `mapstructure:"image"`                // defaults to RunnerConfig.Image
+	CompileTimeLimit   time.Duration `mapstructure:"compile_time_limit"`   // zero takes the default
+	CompileMemoryLimit int64         `mapstructure:"compile_memory_limit"` // mega bytes
+	CompileOutputLimit int64         `mapstructure:"compile_output_limit"` // kilo bytes of diagnostics kept
+}
+
+const DefaultLanguage = "go"
+
+// Limits of the compile phase of languages that set none.
+const (
+	DefaultCompileTimeLimit   = 60 * time.Second
+	DefaultCompileMemoryLimit = 1024 // mega bytes
+	DefaultCompileOutputLimit = 16   // kilo bytes
+)
+
+var DefaultLanguages = map[string]LanguageConfig{
+	"go": {
+		SourceFile:     "main.go",
+		CompileCommand: "go mod tidy && go build -o main .",
+		RunCommand:     "./main",
+	},
+	"c": {
+		SourceFile:     "main.c",
+		CompileCommand: "gcc -O2 -std=c11 -o main main.c -lm",
+		RunCommand:     "./main",
+	},
+	"cpp": {
+		SourceFile:     "main.cpp",
+		CompileCommand: "g++ -O2 -std=c++17 -o main main.cpp",
+		RunCommand:     "./main",
+	},
+	"python": {
+		SourceFile:     "main.py",
+		CompileCommand: "python3 -m py_compile main.py",
+		RunCommand:     "python3 main.py",
+	},
+	"java": {
+		SourceFile:     "Main.java",
+		CompileCommand: "javac Main.java",
+		RunCommand:     "java Main",
+	},
+}
+
+// Language looks up a language in the registry, filling in the defaults it does not set.
+func (r RunnerConfig) Language(id string) (LanguageConfig, error) {
+	if id == "" {
+		id = DefaultLanguage
+	}
+	languages := r.Languages
+	if len(languages) == 0 {
+		languages = DefaultLanguages
+	}
+	language, ok := languages[id]
+	if !ok {
+		return LanguageConfig{}, fmt.Errorf("unsupported language: %s", id)
+	}
+	if language.Image == "" {
+		language.Image = r.Image
+	}
+	if language.CompileTimeLimit <= 0 {
+		language.CompileTimeLimit = DefaultCompileTimeLimit
+	}
+	if language.CompileMemoryLimit <= 0 {
+		language.CompileMemoryLimit = DefaultCompileMemoryLimit
+	}
+	if language.CompileOutputLimit <= 0 {
+		language.CompileOutputLimit = DefaultCompileOutputLimit
+	}
+	return language, nil
+}
+
+// LanguageIds returns the ids of the languages the runner supports, in order.
+func (r RunnerConfig) LanguageIds() []string {
+	languages := r.Languages
+	if len(languages) == 0 {
+		languages = DefaultLanguages
+	}
+	ids := make([]string, 0, len(languages))
+	for id := range languages {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// WorkerCPUs returns the cpuset the worker is pinned to, empty if workers are not pinned.
+func (r RunnerConfig) WorkerCPUs(worker int) string {
+	if len(r.CPUs) == 0 {
+		return ""
+	}
+	return r.CPUs[worker%len(r.CPUs)]
 }
 
 func LoadConfig(configPath string) (*Config, error) {
 	v := viper.New()
 
 	v.SetDefault("manager.address", "manager:8000")
+	v.SetDefault("manager.heartbeat_interval", 10*time.Second)
+	v.SetDefault("manager.api_key", "")
+	v.SetDefault("runner.workers", 1)
+	v.SetDefault("runner.type", DockerRunner)
+	v.SetDefault("runner.pool_size", 2)
+	v.SetDefault("runner.process.work_dir", "/var/lib/judge/runs")
+	v.SetDefault("runner.process.cgroup", "/sys/fs/cgroup/judge")
+	v.SetDefault("runner.process.first_uid", 200000)
+	v.SetDefault("runner.process.uids", 64)
+	v.SetDefault("runner.process.read_only_paths", DefaultReadOnlyPaths)
+	v.SetDefault("shutdown_grace_period", 30*time.Second)
+	if hostname, err := os.Hostname(); err == nil {
+		v.SetDefault("name", hostname)
+	}
 
 	v.SetConfigName("config")
 	v.SetConfigType("yaml")
@@ -52,6 +207,23 @@ func LoadConfig(configPath string) (*Config, error) {
 	if err := v.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("unable to decode config into struct: %w", err)
 	}
+	if config.Manager.APIKey == "" {
+		return nil, errors.New("manager.api_key is not set, ask a superuser for a judge key")
+	}
+	if config.Manager.HeartbeatInterval <= 0 || config.Manager.HeartbeatInterval >= leaseDuration {
+		return nil, fmt.Errorf("manager.heartbeat_interval must be positive and below the %s lease of the manager",
+			leaseDuration)
+	}
+	if config.Runner.Workers < 1 {
+		return nil, errors.New("runner.workers must be at least 1")
+	}
+	if config.Runner.Type != DockerRunner && config.Runner.Type != ProcessRunner {
+		return nil, fmt.Errorf("unknown runner type: %s", config.Runner.Type)
+	}
+	if config.Runner.Type == ProcessRunner && config.Runner.Process.UIDs < 2*config.Runner.Workers {
+		return nil, fmt.Errorf("runner.process.uids must be at least %d for %d workers",
+			2*config.Runner.Workers, config.Runner.Workers)
+	}
 
 	return &config, nil
 }
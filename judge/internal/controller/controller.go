@@ -2,20 +2,26 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/CT1403-2/Code-Judgement/judge/internal/runner"
 	"github.com/CT1403-2/Code-Judgement/proto"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/CT1403-2/Code-Judgement/judge/config"
 )
 
 const (
-	authHeader      = "Authorization"
-	authHeaderValue = "Token 1B926F9E3BF33C879DDBC77D9B679"
+	authHeader = "Authorization"
+
+	minReconnectBackoff = 100 * time.Millisecond
+	maxReconnectBackoff = 10 * time.Second
 )
 
 type controller struct {
@@ -23,8 +29,16 @@ type controller struct {
 	clientBuilder func(*config.Config) (proto.ManagerClient, error)
 	runner        runner.Runner
 	client        proto.ManagerClient
+
+	mu       sync.Mutex
+	inFlight map[string]context.CancelFunc // leased submissions being judged
+
+	idle    chan int // indexes of the workers waiting for a submission
+	workers sync.WaitGroup
+	judging context.Context // outlives the context of Run while draining
 }
 
+// Run leases and judges submissions until ctx is done, then drains the submissions being judged.
 func (c *controller) Run(ctx context.Context) {
 	client, err := c.clientBuilder(c.config)
 	if err != nil {
@@ -32,76 +46,352 @@ func (c *controller) Run(ctx context.Context) {
 		os.Exit(1)
 	}
 	c.client = client
+
+	workers := max(c.config.Runner.Workers, 1)
+	c.idle = make(chan int, workers)
+	for worker := 0; worker < workers; worker++ {
+		c.idle <- worker
+	}
+
+	judging, abortJudging := context.WithCancel(context.WithoutCancel(ctx))
+	defer abortJudging()
+	c.judging = judging
+
+	go c.heartbeat(judging)
 	c.judgePendingSubmissions(ctx)
+	c.drain(abortJudging)
+}
+
+// drain releases the submissions still held once the grace period is over.
+func (c *controller) drain(abortJudging context.CancelFunc) {
+	done := make(chan struct{})
+	go func() {
+		c.workers.Wait()
+		close(done)
+	}()
+
+	logrus.WithField("in_flight", len(c.tracked())).Info("draining submissions")
+	select {
+	case <-done:
+		logrus.Info("drained submissions")
+		return
+	case <-time.After(c.config.ShutdownGracePeriod):
+	}
+
+	unfinished := c.tracked()
+	abortJudging()
+	<-done
+	for _, submissionId := range unfinished {
+		if err := c.releaseSubmission(submissionId); err != nil {
+			logrus.WithError(err).WithField("submission", submissionId).Error("failed to release submission")
+		}
+	}
+	logrus.WithField("released", len(unfinished)).Info("released unfinished submissions")
 }
 
+func (c *controller) releaseSubmission(submissionId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.Manager.Timeout)
+	defer cancel()
+
+	state := proto.SubmissionState_SUBMISSION_STATE_PENDING
+	_, err := c.client.UpdateSubmission(c.withAuth(ctx), &proto.Submission{
+		Id:    &submissionId,
+		State: &state,
+		Judge: &c.config.Name,
+	})
+	return err
+}
+
+// releaseFailed releases a submission that could not be judged rather than waiting out its lease.
+func (c *controller) releaseFailed(ctx context.Context, submissionId string, err error) error {
+	if ctx.Err() != nil {
+		return err
+	}
+	if releaseErr := c.releaseSubmission(submissionId); releaseErr != nil {
+		return errors.Join(err, fmt.Errorf("failed to release submission:\n %w", releaseErr))
+	}
+	return err
+}
+
+// judgePendingSubmissions reconnects with exponential backoff whenever the job stream breaks.
 func (c *controller) judgePendingSubmissions(ctx context.Context) {
-	for { // if possible, receive a stream of submissions here
+	backoff := minReconnectBackoff
+	for {
+		received, err := c.consumeJobs(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if received {
+			backoff = minReconnectBackoff
+		}
+		if status.Code(err) == codes.FailedPrecondition {
+			backoff = maxReconnectBackoff
+		}
+		logrus.WithError(err).WithField("backoff", backoff).Warn("job stream closed, reconnecting")
+
 		select {
 		case <-ctx.Done():
 			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxReconnectBackoff)
+	}
+}
 
-		default:
-			time.Sleep(10 * time.Millisecond)
-			ctxWithTimeout, cancel := context.WithTimeout(ctx, c.config.Manager.Timeout)
-
-			md := metadata.New(map[string]string{
-				authHeader: authHeaderValue,
-			})
-			ctxWithAuth := metadata.NewOutgoingContext(ctxWithTimeout, md)
+// consumeJobs reports whether any submission was received on the stream before it broke.
+func (c *controller) consumeJobs(ctx context.Context) (bool, error) {
+	if err := c.register(ctx); err != nil {
+		return false, err
+	}
 
-			pendingState := proto.SubmissionState_SUBMISSION_STATE_PENDING.String()
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-			submissions, err := c.client.GetSubmissions(ctxWithAuth, &proto.GetSubmissionsRequest{Filters: []*proto.Filter{{Field: "state", Value: pendingState}}})
-			if err != nil {
-				logrus.WithError(err).Error("couldn't get submissions")
-			}
+	stream, err := c.client.StreamJobs(c.withAuth(streamCtx), &proto.StreamJobsRequest{})
+	if err != nil {
+		return false, err
+	}
+	received := false
+	for {
+		submission, err := stream.Recv()
+		if err != nil {
+			return received, err
+		}
+		logrus.WithField("submission", submission.GetId()).Debug("submission pending")
+		if err := c.judgeLeasedSubmissions(ctx); err != nil {
+			return received, err
+		}
+		received = true
+	}
+}
 
-			for _, submission := range submissions.Submissions {
-				err = c.judgeSubmission(ctxWithTimeout, submission)
-				if err != nil {
-					logrus.WithError(err).Error("failed to judge submission")
-				}
-			}
+// judgeLeasedSubmissions leases no more submissions than there are idle workers.
+func (c *controller) judgeLeasedSubmissions(ctx context.Context) error {
+	for {
+		workers, err := c.idleWorkers(ctx)
+		if err != nil {
+			return err
+		}
+		submissions, err := c.leaseJobs(ctx, int32(len(workers)))
+		if err != nil {
+			c.release(workers)
+			return err
+		}
+		for i, submission := range submissions {
+			c.workers.Add(1)
+			go c.work(c.judging, workers[i], submission)
+		}
+		c.release(workers[len(submissions):])
+		if len(submissions) < len(workers) {
+			return nil
+		}
+	}
+}
 
-			cancel()
+func (c *controller) idleWorkers(ctx context.Context) ([]int, error) {
+	var workers []int
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case worker := <-c.idle:
+		workers = append(workers, worker)
+	}
+	for {
+		select {
+		case worker := <-c.idle:
+			workers = append(workers, worker)
+		default:
+			return workers, nil
 		}
 	}
 }
 
-func (c *controller) judgeSubmission(ctx context.Context, submission *proto.Submission) error {
+func (c *controller) release(workers []int) {
+	for _, worker := range workers {
+		c.idle <- worker
+	}
+}
+
+func (c *controller) work(ctx context.Context, worker int, submission *proto.Submission) {
+	defer c.workers.Done()
+	defer c.release([]int{worker})
 
+	if cpus := c.config.Runner.WorkerCPUs(worker); cpus != "" {
+		ctx = runner.WithCPUs(ctx, cpus)
+	}
+	if err := c.judgeSubmission(ctx, submission); err != nil {
+		logrus.WithError(err).WithField("worker", worker).Error("failed to judge submission")
+	}
+}
+
+func (c *controller) register(ctx context.Context) error {
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, c.config.Manager.Timeout)
 	defer cancel()
 
-	md := metadata.New(map[string]string{
-		authHeader: authHeaderValue,
+	hostname, _ := os.Hostname()
+	_, err := c.client.RegisterJudge(c.withAuth(ctxWithTimeout), &proto.Judge{
+		Id:        c.config.Name,
+		Hostname:  hostname,
+		Languages: c.config.Runner.LanguageIds(),
+		Capacity:  int32(cap(c.idle)),
 	})
-	ctxWithAuth := metadata.NewOutgoingContext(ctxWithTimeout, md)
+	if err != nil {
+		return fmt.Errorf("failed to register judge:\n %w", err)
+	}
+	return nil
+}
+
+func (c *controller) leaseJobs(ctx context.Context, count int32) ([]*proto.Submission, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, c.config.Manager.Timeout)
+	defer cancel()
 
-	response, err := c.client.GetQuestion(ctxWithAuth, &proto.ID{Value: submission.QuestionId})
+	response, err := c.client.LeaseJobs(c.withAuth(ctxWithTimeout), &proto.LeaseJobsRequest{
+		Judge: c.config.Name,
+		Count: count,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to judge submission:\n %w", err)
+		return nil, fmt.Errorf("failed to lease jobs:\n %w", err)
 	}
+	return response.Submissions, nil
+}
 
-	state := proto.SubmissionState_SUBMISSION_STATE_JUDGING
-	submission.State = &state
-	_, err = c.client.UpdateSubmission(ctxWithAuth, submission)
+func (c *controller) judgeSubmission(ctx context.Context, submission *proto.Submission) error {
+	ctx = c.track(ctx, submission.GetId())
+	defer c.abort(submission.GetId())
+
+	question, err := c.getQuestion(ctx, submission.QuestionId)
 	if err != nil {
-		return fmt.Errorf("failed to judge submission:\n %w", err)
+		return c.releaseFailed(ctx, submission.GetId(), fmt.Errorf("failed to judge submission:\n %w", err))
 	}
-	// todo: check if is updated
 
-	updatedState, err := c.runner.Run(ctx, response.Question, submission)
+	result, err := c.runner.Run(ctx, question, submission)
 	if err != nil {
-		return fmt.Errorf("failed to judge submission:\n %w", err)
+		return c.releaseFailed(ctx, submission.GetId(), fmt.Errorf("failed to judge submission:\n %w", err))
 	}
 
-	submission.State = updatedState
-	_, err = c.client.UpdateSubmission(ctxWithAuth, submission)
+	submission.State = &result.State
+	submission.Score = &result.Score
+	submission.MaxScore = &result.MaxScore
+	if result.FailedTest != 0 {
+		submission.FailedTest = &result.FailedTest
+	}
+	if result.CompileTime != 0 {
+		submission.CompileTime = &result.CompileTime
+	}
+	if result.State == proto.SubmissionState_SUBMISSION_STATE_COMPILE_ERROR {
+		submission.CompilerOutput = &result.CompilerOutput
+	} else {
+		submission.WallTime = &result.WallTime
+		submission.CpuTime = &result.CPUTime
+		submission.Memory = &result.Memory
+	}
+	if result.State == proto.SubmissionState_SUBMISSION_STATE_RUNTIME_ERROR {
+		if result.Signal != 0 {
+			submission.Signal = &result.Signal
+		} else {
+			submission.ExitCode = &result.ExitCode
+		}
+	}
+	submission.Judge = &c.config.Name
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, c.config.Manager.Timeout)
+	defer cancel()
+	updated, err := c.client.UpdateSubmission(c.withAuth(ctxWithTimeout), submission)
 	if err != nil {
 		return fmt.Errorf("failed to judge submission:\n %w", err)
 	}
+	if !updated.GetUpdated() {
+		logrus.WithField("submission", submission.GetId()).Warn("lease expired before the result was reported")
+	}
+
+	return nil
+}
+
+func (c *controller) getQuestion(ctx context.Context, questionId string) (*proto.Question, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, c.config.Manager.Timeout)
+	defer cancel()
+
+	response, err := c.client.GetQuestion(c.withAuth(ctxWithTimeout), &proto.ID{Value: questionId})
+	if err != nil {
+		return nil, err
+	}
+	return response.Question, nil
+}
+
+// heartbeat aborts the judging of submissions whose lease was lost.
+func (c *controller) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(c.config.Manager.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := c.renewLeases(ctx); err != nil {
+			logrus.WithError(err).Warn("failed to send heartbeat")
+		}
+	}
+}
 
+// renewLeases is sent even when nothing is being judged, as the judge's liveness.
+func (c *controller) renewLeases(ctx context.Context) error {
+	submissionIds := c.tracked()
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, c.config.Manager.Timeout)
+	defer cancel()
+
+	response, err := c.client.Heartbeat(c.withAuth(ctxWithTimeout), &proto.HeartbeatRequest{
+		Judge:         c.config.Name,
+		SubmissionIds: submissionIds,
+	})
+	if err != nil {
+		return err
+	}
+	renewed := make(map[string]bool, len(response.Renewed))
+	for _, id := range response.Renewed {
+		renewed[id] = true
+	}
+	for _, id := range submissionIds {
+		if !renewed[id] {
+			logrus.WithField("submission", id).Warn("lease lost, aborting judging")
+			c.abort(id)
+		}
+	}
 	return nil
 }
+
+func (c *controller) track(ctx context.Context, submissionId string) context.Context {
+	ctx, cancel := context.WithCancel(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inFlight == nil {
+		c.inFlight = make(map[string]context.CancelFunc)
+	}
+	c.inFlight[submissionId] = cancel
+	return ctx
+}
+
+func (c *controller) abort(submissionId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cancel, ok := c.inFlight[submissionId]; ok {
+		cancel()
+		delete(c.inFlight, submissionId)
+	}
+}
+
+func (c *controller) tracked() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	submissionIds := make([]string, 0, len(c.inFlight))
+	for id := range c.inFlight {
+		submissionIds = append(submissionIds, id)
+	}
+	return submissionIds
+}
+
+func (c *controller) withAuth(ctx context.Context) context.Context {
+	md := metadata.New(map[string]string{
+		authHeader: "Token " + c.config.Manager.APIKey,
+	})
+	return metadata.NewOutgoingContext(ctx, md)
+}
@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/CT1403-2/Code-Judgement/judge/config"
+	"github.com/CT1403-2/Code-Judgement/proto"
+	"github.com/sirupsen/logrus"
+)
+
+// Exit codes of a checker, following the testlib convention.
+const (
+	checkerOK                = 0
+	checkerWrongAnswer       = 1
+	checkerPresentationError = 2
+	checkerPartial           = 7
+)
+
+const (
+	checkerTimeLimit   = 10000 // milliseconds per test
+	checkerMemoryLimit = 512   // mega bytes
+)
+
+// runChecker runs the question's checker in a suite of its own against the
+// contestant outputs. Only tests the program exited cleanly on are checked.
+func runChecker(ctx context.Context, cfg *config.Config, runner suiteRunner, logger *logrus.Entry, question *proto.Question,
+	tests []*proto.TestCase, outputs *suiteOutput) (answerChecker, error) {
+	language, err := cfg.Runner.Language(question.GetCheckerLanguage())
+	if err != nil {
+		return nil, fmt.Errorf("invalid checker: %w", err)
+	}
+
+	suite := SuiteConfig{Code: question.GetChecker()}
+	// positions maps a test to its position in the checker suite
+	positions := make(map[int]int)
+	for i, test := range tests {
+		output, ok := outputs.tests[i]
+		if !ok || output.Status != 0 {
+			continue
+		}
+		positions[i] = len(suite.Tests)
+		suite.Tests = append(suite.Tests, SuiteTest{
+			Input:  test.GetInput(),
+			Output: output.stdout,
+			Answer: test.GetOutput(),
+		})
+	}
+	if outputs.compileFailed() || len(suite.Tests) == 0 {
+		return exactMatch, nil
+	}
+
+	limitations := &proto.Limitations{Duration: checkerTimeLimit, Memory: checkerMemoryLimit}
+	checkerOutputs, _, err := runner.runSuite(ctx, logger.WithField("checker", true), checkScript,
+		limitations, language, nil, suite)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(index int, _ *proto.TestCase, _ *testOutput) (proto.SubmissionState, float64) {
+		if checkerOutputs.compileFailed() {
+			return proto.SubmissionState_SUBMISSION_STATE_FAILED, 0
+		}
+		output, ok := checkerOutputs.tests[positions[index]]
+		if !ok {
+			return proto.SubmissionState_SUBMISSION_STATE_FAILED, 0
+		}
+		return checkerVerdict(output)
+	}, nil
+}
+
+// checkerVerdict judges a test by how its checker exited. A partially accepted test is a wrong answer
+// earning the fraction of its points the checker printed, which only per test subtasks award.
+func checkerVerdict(output *testOutput) (proto.SubmissionState, float64) {
+	switch output.Status {
+	case checkerOK:
+		return proto.SubmissionState_SUBMISSION_STATE_OK, 1
+	case checkerWrongAnswer, checkerPresentationError:
+		return proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER, 0
+	case checkerPartial:
+		fraction, err := strconv.ParseFloat(strings.TrimSpace(output.stdout), 64)
+		if err != nil || !(fraction >= 0 && fraction <= 1) {
+			return proto.SubmissionState_SUBMISSION_STATE_FAILED, 0
+		}
+		return proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER, fraction
+	default:
+		return proto.SubmissionState_SUBMISSION_STATE_FAILED, 0
+	}
+}
@@ -0,0 +1,35 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/CT1403-2/Code-Judgement/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckerVerdict(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   int64
+		stdout   string
+		expected proto.SubmissionState
+		credit   float64
+	}{
+		{"accepted", checkerOK, "", proto.SubmissionState_SUBMISSION_STATE_OK, 1},
+		{"wrong answer", checkerWrongAnswer, "", proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER, 0},
+		{"presentation error", checkerPresentationError, "", proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER, 0},
+		{"partial", checkerPartial, "0.25\n", proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER, 0.25},
+		{"partial without fraction", checkerPartial, "", proto.SubmissionState_SUBMISSION_STATE_FAILED, 0},
+		{"partial over one", checkerPartial, "1.5", proto.SubmissionState_SUBMISSION_STATE_FAILED, 0},
+		{"partial not a number", checkerPartial, "NaN", proto.SubmissionState_SUBMISSION_STATE_FAILED, 0},
+		{"crashed", 139, "", proto.SubmissionState_SUBMISSION_STATE_FAILED, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			state, credit := checkerVerdict(&testOutput{execution: execution{Status: tc.status}, stdout: tc.stdout})
+			require.Equal(t, tc.expected, state)
+			require.Equal(t, tc.credit, credit)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package runner
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/CT1403-2/Code-Judgement/proto"
+)
+
+// defaultFloatEpsilon is used by COMPARISON_MODE_FLOAT when the question sets neither epsilon.
+const defaultFloatEpsilon = 1e-6
+
+// answerChecker decides the verdict of a test whose program exited cleanly, and the fraction of the
+// points of the test it earns: 1 when accepted, 0 when rejected and in between when accepted partially.
+type answerChecker func(index int, test *proto.TestCase, output *testOutput) (proto.SubmissionState, float64)
+
+func exactMatch(_ int, test *proto.TestCase, output *testOutput) (proto.SubmissionState, float64) {
+	return accepted(output.stdout == test.GetOutput())
+}
+
+func accepted(ok bool) (proto.SubmissionState, float64) {
+	if ok {
+		return proto.SubmissionState_SUBMISSION_STATE_OK, 1
+	}
+	return proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER, 0
+}
+
+// comparator returns the built-in answer checker selected by the question.
+func comparator(question *proto.Question) answerChecker {
+	var equal func(expected, actual string) bool
+	switch question.GetComparisonMode() {
+	case proto.ComparisonMode_COMPARISON_MODE_IGNORE_TRAILING_WHITESPACE:
+		equal = equalIgnoringTrailingWhitespace
+	case proto.ComparisonMode_COMPARISON_MODE_TOKENS:
+		equal = func(expected, actual string) bool {
+			return equalTokens(expected, actual, func(e, a string) bool { return e == a })
+		}
+	case proto.ComparisonMode_COMPARISON_MODE_CASE_INSENSITIVE:
+		equal = func(expected, actual string) bool {
+			return equalTokens(expected, actual, strings.EqualFold)
+		}
+	case proto.ComparisonMode_COMPARISON_MODE_FLOAT:
+		absolute, relative := question.GetAbsoluteEpsilon(), question.GetRelativeEpsilon()
+		if absolute == 0 && relative == 0 {
+			absolute = defaultFloatEpsilon
+		}
+		equal = func(expected, actual string) bool {
+			return equalTokens(expected, actual, func(e, a string) bool {
+				return equalFloats(e, a, absolute, relative)
+			})
+		}
+	default:
+		return exactMatch
+	}
+
+	return func(_ int, test *proto.TestCase, output *testOutput) (proto.SubmissionState, float64) {
+		return accepted(equal(test.GetOutput(), output.stdout))
+	}
+}
+
+func equalIgnoringTrailingWhitespace(expected, actual string) bool {
+	expectedLines := trimmedLines(expected)
+	actualLines := trimmedLines(actual)
+	if len(expectedLines) != len(actualLines) {
+		return false
+	}
+	for i := range expectedLines {
+		if expectedLines[i] != actualLines[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// trimmedLines splits s into lines without trailing whitespace, dropping trailing empty lines.
+func trimmedLines(s string) []string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r\f\v")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func equalTokens(expected, actual string, equal func(expected, actual string) bool) bool {
+	expectedTokens := strings.Fields(expected)
+	actualTokens := strings.Fields(actual)
+	if len(expectedTokens) != len(actualTokens) {
+		return false
+	}
+	for i := range expectedTokens {
+		if !equal(expectedTokens[i], actualTokens[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// equalFloats compares two tokens as numbers when the expected one is a finite
+// number, and byte for byte otherwise.
+func equalFloats(expected, actual string, absolute, relative float64) bool {
+	e, err := strconv.ParseFloat(expected, 64)
+	if err != nil || math.IsInf(e, 0) || math.IsNaN(e) {
+		return expected == actual
+	}
+	a, err := strconv.ParseFloat(actual, 64)
+	if err != nil || math.IsInf(a, 0) || math.IsNaN(a) {
+		return false
+	}
+	diff := math.Abs(e - a)
+	return diff <= absolute || diff <= relative*math.Abs(e)
+}
@@ -0,0 +1,85 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/CT1403-2/Code-Judgement/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComparator(t *testing.T) {
+	testCases := []struct {
+		name     string
+		question *proto.Question
+		expected string
+		actual   string
+		accepted bool
+	}{
+		{"exact match", &proto.Question{}, "1 2\n", "1 2\n", true},
+		{"exact trailing newline", &proto.Question{}, "1 2\n", "1 2", false},
+		{"exact mode trailing space", exactQuestion(), "1 2", "1 2 ", false},
+
+		{"trailing newline", trailingWhitespaceQuestion(), "1 2\n", "1 2", true},
+		{"trailing spaces and lines", trailingWhitespaceQuestion(), "1 2\n3\n", "1 2  \n3\t\n\n\n", true},
+		{"windows line endings", trailingWhitespaceQuestion(), "1 2\n3\n", "1 2\r\n3\r\n", true},
+		{"leading space matters", trailingWhitespaceQuestion(), "1 2", " 1 2", false},
+		{"inner space matters", trailingWhitespaceQuestion(), "1 2", "1  2", false},
+		{"line breaks matter", trailingWhitespaceQuestion(), "1 2", "1\n2", false},
+
+		{"tokens across lines", tokensQuestion(), "1 2 3", "1\n2\n  3\n", true},
+		{"tokens missing", tokensQuestion(), "1 2 3", "1 2", false},
+		{"tokens case", tokensQuestion(), "YES", "yes", false},
+
+		{"case insensitive", caseInsensitiveQuestion(), "YES\nNo", "yes no", true},
+		{"case insensitive wrong", caseInsensitiveQuestion(), "YES", "YEP", false},
+
+		{"float default epsilon", floatQuestion(0, 0), "0.3333333", "0.33333331", true},
+		{"float default epsilon wrong", floatQuestion(0, 0), "0.333", "0.334", false},
+		{"float absolute", floatQuestion(1e-2, 0), "1.50 2", "1.505 2.001", true},
+		{"float absolute wrong", floatQuestion(1e-2, 0), "1.50", "1.52", false},
+		{"float relative", floatQuestion(0, 1e-3), "1000000", "1000500", true},
+		{"float relative wrong", floatQuestion(0, 1e-3), "1000000", "1002000", false},
+		{"float non number token", floatQuestion(0, 0), "answer 1.0", "answer 1", true},
+		{"float non number token wrong", floatQuestion(0, 0), "answer 1.0", "Answer 1", false},
+		{"float not a number", floatQuestion(0, 0), "1.0", "one", false},
+		{"float nan", floatQuestion(0, 0), "1.0", "NaN", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			check := comparator(tc.question)
+			state, credit := check(0, &proto.TestCase{Output: tc.expected}, &testOutput{stdout: tc.actual})
+			if tc.accepted {
+				require.Equal(t, proto.SubmissionState_SUBMISSION_STATE_OK, state)
+				require.Equal(t, 1.0, credit)
+			} else {
+				require.Equal(t, proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER, state)
+				require.Zero(t, credit)
+			}
+		})
+	}
+}
+
+func exactQuestion() *proto.Question {
+	return &proto.Question{ComparisonMode: proto.ComparisonMode_COMPARISON_MODE_EXACT}
+}
+
+func trailingWhitespaceQuestion() *proto.Question {
+	return &proto.Question{ComparisonMode: proto.ComparisonMode_COMPARISON_MODE_IGNORE_TRAILING_WHITESPACE}
+}
+
+func tokensQuestion() *proto.Question {
+	return &proto.Question{ComparisonMode: proto.ComparisonMode_COMPARISON_MODE_TOKENS}
+}
+
+func caseInsensitiveQuestion() *proto.Question {
+	return &proto.Question{ComparisonMode: proto.ComparisonMode_COMPARISON_MODE_CASE_INSENSITIVE}
+}
+
+func floatQuestion(absolute, relative float64) *proto.Question {
+	return &proto.Question{
+		ComparisonMode:  proto.ComparisonMode_COMPARISON_MODE_FLOAT,
+		AbsoluteEpsilon: absolute,
+		RelativeEpsilon: relative,
+	}
+}
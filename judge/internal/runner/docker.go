@@ -1,14 +1,21 @@
 package runner
 
 import (
+	"archive/tar"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/sirupsen/logrus"
+	"io"
+	"math"
+	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/CT1403-2/Code-Judgement/judge/config"
 	"github.com/CT1403-2/Code-Judgement/proto"
@@ -17,100 +24,208 @@ import (
 )
 
 const (
-	timeOutError      = "timeout: sending signal TERM to command './main'\n"
-	didntCompileError = "timeout: failed to run command './main': No such file or directory\n"
+	compileScript = "./compile.sh"
+	runScript     = "./run.sh"
+	checkScript   = "./check.sh"
+
+	judgeDir          = "/playground/judge"
+	resultsDir        = "/playground/judge/results"
+	compileResultFile = "compile.json"
+	compileOutputFile = "compile.output"
+
+	defaultOutputLimit = 16 * 1024 // kilobytes
+	interactorPrefix   = "interactor-"
+
+	// signalExitBase is added to the signal number by the shell when a program is killed by a signal.
+	signalExitBase = 128
+	maxSignal      = 64
+	sigxfsz        = 25
 )
 
 type dockerRunner struct {
 	config *config.Config
+	pool   *containerPool
 }
 
+// SuiteConfig is what a script runs.
 type SuiteConfig struct {
-	Code  []byte `json:"code"`
-	Input string `json:"input"`
+	Code       []byte
+	Arguments  bool
+	Interactor []byte
+	Tests      []SuiteTest
 }
 
-func (d dockerRunner) Run(ctx context.Context, question *proto.Question, submission *proto.Submission) (*proto.SubmissionState, error) {
-	logger := logrus.WithFields(logrus.Fields{"submission_id": *submission.Id})
-	logger.Info("Starting submission evaluation")
+type SuiteTest struct {
+	Input  string
+	Output string // contestant output, only sent to checkers
+	Answer string // expected output, only sent to checkers
+}
 
-	docker, err := createDockerClient(ctx)
-	if err != nil {
-		return nil, err
-	}
-	defer docker.Close()
+// suiteManifest is the suite.json read by the scripts.
+type suiteManifest struct {
+	Arguments   bool `json:"arguments"`
+	Interactive bool `json:"interactive"`
+	Tests       int  `json:"tests"`
+}
+
+// execution is how a program ended and what it used.
+type execution struct {
+	Phase      string  `json:"phase"`
+	Status     int64   `json:"status"`
+	TimedOut   bool    `json:"timed_out"`
+	WallTime   float64 `json:"wall_time"`   // seconds
+	UserTime   float64 `json:"user_time"`   // seconds
+	SystemTime float64 `json:"system_time"` // seconds
+	Memory     int64   `json:"memory"`      // peak resident set size in kilobytes
+	OOMKilled  bool    `json:"oom_killed"`
+}
+
+type testOutput struct {
+	execution
+	stdout          string // truncated to the output limit
+	stderr          string // truncated to maxContainerLogs
+	outputTruncated bool
+	interactor      *execution // nil unless the question is interactive
+}
+
+func (o *testOutput) outputLimitExceeded() bool {
+	_, signal := exitStatus(o.Status)
+	return o.outputTruncated || signal == sigxfsz
+}
+
+type suiteOutput struct {
+	compile                *execution // nil if the language has no compile step
+	compileOutput          string     // truncated to the compile output limit of the language
+	compileOutputTruncated bool
+	interactorCompile      *execution
+	tests                  map[int]*testOutput
+}
 
-	suite := SuiteConfig{
-		Code:  submission.Code,
-		Input: *question.Input,
+func (o *suiteOutput) compileFailed() bool {
+	return o.compile != nil && o.compile.Status != 0
+}
+
+// compilerOutput tells when the compiler was stopped by the limits of the compile phase.
+func (o *suiteOutput) compilerOutput(isOOMKilled bool) string {
+	output := strings.ToValidUTF8(o.compileOutput, "\uFFFD")
+	if o.compileOutputTruncated {
+		output += truncatedMarker
+	}
+	switch {
+	case o.compile.TimedOut:
+		output += "\n... (compile time limit exceeded)"
+	case isOOMKilled || o.compile.OOMKilled:
+		output += "\n... (compile memory limit exceeded)"
 	}
-	jsonSuite, err := json.Marshal(suite)
+	return output
+}
+
+func (o *suiteOutput) interactorCompileFailed() bool {
+	return o.interactorCompile != nil && o.interactorCompile.Status != 0
+}
+
+func (d dockerRunner) Run(ctx context.Context, question *proto.Question, submission *proto.Submission) (*Result, error) {
+	return runSubmission(ctx, d.config, d, question, submission)
+}
+
+func (d dockerRunner) Close() error {
+	return d.pool.close()
+}
+
+// runSuite compiles the suite and runs script over it in a pooled container.
+func (d dockerRunner) runSuite(ctx context.Context, logger *logrus.Entry, script string,
+	limitations *proto.Limitations, language config.LanguageConfig, interactor *config.LanguageConfig,
+	suite SuiteConfig) (*suiteOutput, bool, error) {
+	docker, err := d.pool.client(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal input data: %w", err)
-	}
-
-	containerConfig, hostConfig := d.prepareContainerConfig(question, jsonSuite)
-	containerName := fmt.Sprintf("submission-%s-judge", *submission.Id)
-	resp, err := docker.ContainerCreate(
-		ctx,
-		containerConfig,
-		hostConfig,
-		nil,
-		nil,
-		containerName,
-	)
+		return nil, false, err
+	}
+	archive, err := suiteArchive(suite)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create container: %w", err)
+		return nil, false, err
 	}
 
-	containerID := resp.ID
-	logger.WithField("container_id", containerID).Info("Container created")
+	key := poolKey{image: language.Image, cpus: cpusFromContext(ctx)}
+	containerID, err := d.pool.acquire(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+	logger = logger.WithField("container_id", containerID)
+	logger.Info("Container acquired")
 
+	reusable := false
 	defer func() {
-		removeOptions := container.RemoveOptions{
-			RemoveVolumes: true,
-			Force:         true,
-		}
-		if err := docker.ContainerRemove(context.Background(), containerID, removeOptions); err != nil {
-			logger.WithError(err).Warn("Failed to remove container")
-		} else {
-			logger.Info("Container removed")
-		}
+		d.pool.release(key, containerID, reusable)
 	}()
 
-	if err := docker.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
-		return nil, fmt.Errorf("failed to start container: %w", err)
+	limit := func(memory int64) error {
+		updateConfig := container.UpdateConfig{Resources: d.prepareResources(memory)}
+		if _, err := docker.ContainerUpdate(ctx, containerID, updateConfig); err != nil {
+			return fmt.Errorf("failed to limit container: %w", err)
+		}
+		return nil
 	}
-	logger.Info("Container started")
 
-	var inspect container.InspectResponse
-	statusCh, errCh := docker.ContainerWait(ctx, containerID, container.WaitConditionNotRunning)
-	var statusCode int64
+	compileMemory := language.CompileMemoryLimit
+	if interactor != nil {
+		compileMemory = max(compileMemory, interactor.CompileMemoryLimit)
+	}
+	if err := limit(compileMemory); err != nil {
+		return nil, false, err
+	}
+	if err := copyToContainer(ctx, docker, containerID, judgeDir, archive); err != nil {
+		return nil, false, err
+	}
 
-	select {
-	case err := <-errCh:
-		if err != nil {
-			return nil, fmt.Errorf("error waiting for container: %w", err)
+	statusCode, _, stderrStr, err := execute(ctx, docker, containerID, d.prepareCompileConfig(language, interactor), nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("error compiling suite: %w", err)
+	}
+	logger.WithField("status_code", statusCode).Info("Suite compilation completed")
+	logger.WithField("stderr", stderrStr).Debug("Suite logs fetched")
+
+	if statusCode == 0 {
+		if err := limit(limitations.GetMemory()); err != nil {
+			return nil, false, err
 		}
-	case status := <-statusCh:
-		statusCode = status.StatusCode
-		inspect, err = docker.ContainerInspect(ctx, containerID)
+		statusCode, _, stderrStr, err = execute(ctx, docker, containerID,
+			d.prepareExecConfig(limitations, language, interactor, script), nil)
 		if err != nil {
-			return nil, fmt.Errorf("couldn't inspect container: %w", err)
+			return nil, false, fmt.Errorf("error running suite: %w", err)
 		}
+		logger.WithField("status_code", statusCode).Info("Suite execution completed")
+		logger.WithField("stderr", stderrStr).Debug("Suite logs fetched")
 	}
 
-	logger.WithField("status_code", statusCode).Info("Container execution completed")
+	inspect, err := docker.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, false, fmt.Errorf("couldn't inspect container: %w", err)
+	}
 
-	stdoutStr, stderrStr, err := getContainerLogs(ctx, docker, containerID)
+	outputs, err := getSuiteOutput(ctx, docker, containerID, outputLimit(limitations), language.CompileOutputLimit*1024)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
+	reusable = inspect.State.Running && !inspect.State.OOMKilled
+	return outputs, inspect.State.OOMKilled, nil
+}
+
+func (r *Result) addUsage(e execution) {
+	r.WallTime = max(r.WallTime, milliseconds(e.WallTime))
+	r.CPUTime = max(r.CPUTime, milliseconds(e.UserTime+e.SystemTime))
+	r.Memory = max(r.Memory, e.Memory)
+}
 
-	result := d.evaluateResult(statusCode, stdoutStr, stderrStr, question, inspect.State.OOMKilled)
-	logger.WithField("result", result.String()).Info("Submission evaluated")
+func milliseconds(seconds float64) int32 {
+	return int32(math.Round(seconds * 1000))
+}
 
-	return result, nil
+// testCases falls back to the input and output of questions created before test cases existed.
+func testCases(question *proto.Question) []*proto.TestCase {
+	if len(question.TestCases) > 0 {
+		return question.TestCases
+	}
+	return []*proto.TestCase{{Input: question.GetInput(), Output: question.GetOutput()}}
 }
 
 func createDockerClient(ctx context.Context) (*client.Client, error) {
@@ -123,67 +238,263 @@ func createDockerClient(ctx context.Context) (*client.Client, error) {
 	return docker, nil
 }
 
-func (d dockerRunner) prepareContainerConfig(question *proto.Question, jsonSuite []byte) (*container.Config, *container.HostConfig) {
-	containerConfig := &container.Config{
-		Image:           d.config.Runner.Image,
-		Cmd:             []string{"/bin/sh", "-c", "echo '" + string(jsonSuite) + "' > /playground/app/suite.json && ./run.sh"},
-		Tty:             false,
-		NetworkDisabled: true,
-		Env:             []string{fmt.Sprintf("TIMEOUT=%d", question.Limitations.Duration/1000)},
+func (d dockerRunner) prepareCompileConfig(language config.LanguageConfig,
+	interactor *config.LanguageConfig) container.ExecOptions {
+	execConfig := container.ExecOptions{
+		Cmd: []string{compileScript},
+		Env: []string{
+			fmt.Sprintf("COMPILE_TIMEOUT=%.3f", language.CompileTimeLimit.Seconds()),
+			"SOURCE_FILE=" + language.SourceFile,
+			"COMPILE_COMMAND=" + language.CompileCommand,
+		},
 	}
+	if interactor != nil {
+		execConfig.Env = append(execConfig.Env,
+			fmt.Sprintf("INTERACTOR_COMPILE_TIMEOUT=%.3f", interactor.CompileTimeLimit.Seconds()),
+			"INTERACTOR_SOURCE_FILE="+interactor.SourceFile,
+			"INTERACTOR_COMPILE_COMMAND="+interactor.CompileCommand,
+		)
+	}
+	return execConfig
+}
 
-	hostConfig := &container.HostConfig{
-		Resources: container.Resources{
-			Memory:           question.Limitations.Memory * 1024 * 1024,
-			MemorySwap:       question.Limitations.Memory * 1024 * 1024,
-			MemorySwappiness: &[]int64{0}[0],
-			CPUPeriod:        100000,
-			CPUQuota:         100000,
+func (d dockerRunner) prepareExecConfig(limitations *proto.Limitations, language config.LanguageConfig,
+	interactor *config.LanguageConfig, script string) container.ExecOptions {
+	execConfig := container.ExecOptions{
+		Cmd: []string{script},
+		Env: []string{
+			fmt.Sprintf("TIMEOUT=%.3f", float64(limitations.Duration)/1000),
+			"RUN_COMMAND=" + language.RunCommand,
 		},
 	}
+	if interactor != nil {
+		interactorTimeout := max(limitations.Duration, interactorTimeLimit)
+		execConfig.Env = append(execConfig.Env,
+			fmt.Sprintf("INTERACTOR_TIMEOUT=%.3f", float64(interactorTimeout)/1000),
+			"INTERACTOR_RUN_COMMAND="+interactor.RunCommand,
+		)
+	}
+	return execConfig
+}
 
-	return containerConfig, hostConfig
+func (d dockerRunner) prepareResources(memory int64) container.Resources {
+	return container.Resources{
+		Memory:     memory * 1024 * 1024,
+		MemorySwap: memory * 1024 * 1024,
+	}
 }
 
-func getContainerLogs(ctx context.Context, docker *client.Client, containerID string) (string, string, error) {
-	out, err := docker.ContainerLogs(ctx, containerID, container.LogsOptions{
-		ShowStdout: true,
-		ShowStderr: true,
+// suiteArchive packs the suite as a tar, e.g. suite.json, code and tests/0.input.
+func suiteArchive(suite SuiteConfig) (*bytes.Buffer, error) {
+	manifest, err := json.Marshal(suiteManifest{
+		Arguments:   suite.Arguments,
+		Interactive: len(suite.Interactor) > 0,
+		Tests:       len(suite.Tests),
 	})
 	if err != nil {
-		return "", "", fmt.Errorf("failed to get container logs: %w", err)
+		return nil, fmt.Errorf("failed to marshal suite: %w", err)
+	}
+
+	var buf bytes.Buffer
+	archive := tar.NewWriter(&buf)
+	modTime := time.Now()
+	addDir := func(name string) error {
+		return archive.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name, Mode: 0o755, ModTime: modTime})
+	}
+	addFile := func(name string, content []byte) error {
+		header := &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: int64(len(content)), ModTime: modTime}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := archive.Write(content)
+		return err
+	}
+
+	err = errors.Join(
+		addDir("suite/"),
+		addDir("suite/tests/"),
+		addFile("suite/suite.json", manifest),
+		addFile("suite/code", suite.Code),
+	)
+	if len(suite.Interactor) > 0 {
+		err = errors.Join(err, addFile("suite/interactor", suite.Interactor))
+	}
+	for i, test := range suite.Tests {
+		err = errors.Join(err,
+			addFile(fmt.Sprintf("suite/tests/%d.input", i), []byte(test.Input)),
+			addFile(fmt.Sprintf("suite/tests/%d.output", i), []byte(test.Output)),
+			addFile(fmt.Sprintf("suite/tests/%d.answer", i), []byte(test.Answer)),
+		)
+	}
+	err = errors.Join(err, archive.Close())
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive suite: %w", err)
 	}
-	defer out.Close()
+	return &buf, nil
+}
+
+// copyToContainer extracts with tar in the container so that the files belong to its user.
+func copyToContainer(ctx context.Context, docker *client.Client, containerID, dir string, archive io.Reader) error {
+	status, _, stderr, err := execute(ctx, docker, containerID,
+		container.ExecOptions{Cmd: []string{"tar", "-x", "-C", dir}}, archive)
+	if err != nil {
+		return fmt.Errorf("failed to copy into container: %w", err)
+	}
+	if status != 0 {
+		return fmt.Errorf("failed to copy into container, tar exited with status %d: %s", status, stderr)
+	}
+	return nil
+}
 
-	var stdout, stderr bytes.Buffer
-	_, err = stdcopy.StdCopy(&stdout, &stderr, out)
+// getContainerLogs reads the output of a command in a container, truncating each stream to maxContainerLogs bytes.
+func getContainerLogs(out io.Reader) (string, string, error) {
+	stdout := &limitedBuffer{limit: maxContainerLogs}
+	stderr := &limitedBuffer{limit: maxContainerLogs}
+	_, err := stdcopy.StdCopy(stdout, stderr, out)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to read container output: %w", err)
 	}
 
-	return stdout.String(), stderr.String(), nil
+	return stdout.text(), stderr.text(), nil
 }
 
-func (d dockerRunner) evaluateResult(statusCode int64, stdoutStr, stderrStr string, question *proto.Question, isOOMKilled bool) *proto.SubmissionState {
-	if isOOMKilled {
-		return statePtr(proto.SubmissionState_SUBMISSION_STATE_MEMORY_LIMIT_EXCEEDED)
+// outputLimit returns how many bytes of stdout a test can print, at most the size of a file in the sandbox.
+func outputLimit(limitations *proto.Limitations) int64 {
+	limit := limitations.GetOutput()
+	if limit <= 0 {
+		limit = defaultOutputLimit
 	}
+	return min(limit*1024, fileSizeLimit)
+}
 
-	if statusCode == 0 && stdoutStr == *question.Output {
-		return statePtr(proto.SubmissionState_SUBMISSION_STATE_OK)
+// getSuiteOutput copies the results written by the scripts out of the container.
+func getSuiteOutput(ctx context.Context, docker *client.Client, containerID string,
+	outputLimit, compileOutputLimit int64) (*suiteOutput, error) {
+	reader, _, err := docker.CopyFromContainer(ctx, containerID, resultsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy test results: %w", err)
 	}
+	defer reader.Close()
+
+	outputs := &suiteOutput{tests: make(map[int]*testOutput)}
+	finished := make(map[int]bool)
+	archive := tar.NewReader(reader)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read test results: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Base(header.Name)
+		limit := int64(maxContainerLogs)
+		if path.Ext(name) == ".stdout" {
+			limit = outputLimit
+		} else if name == compileOutputFile {
+			limit = compileOutputLimit
+		}
+		content, truncated, err := readLimited(archive, limit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read test results: %w", err)
+		}
+
+		isInteractor := strings.HasPrefix(name, interactorPrefix)
+		name = strings.TrimPrefix(name, interactorPrefix)
+
+		if name == compileResultFile {
+			compile := &execution{}
+			if err := json.Unmarshal(content, compile); err != nil {
+				return nil, fmt.Errorf("invalid compile result: %w", err)
+			}
+			if isInteractor {
+				outputs.interactorCompile = compile
+			} else {
+				outputs.compile = compile
+			}
+			continue
+		}
+		if name == compileOutputFile {
+			if !isInteractor {
+				outputs.compileOutput = string(content)
+				outputs.compileOutputTruncated = truncated
+			}
+			continue
+		}
 
-	if statusCode == 123 {
-		if stderrStr == timeOutError {
-			return statePtr(proto.SubmissionState_SUBMISSION_STATE_TIME_LIMIT_EXCEEDED)
+		ext := path.Ext(name)
+		index, err := strconv.Atoi(strings.TrimSuffix(name, ext))
+		if err != nil {
+			continue
+		}
+		output, ok := outputs.tests[index]
+		if !ok {
+			output = &testOutput{}
+			outputs.tests[index] = output
+		}
+		if isInteractor {
+			if ext == ".json" {
+				output.interactor = &execution{}
+				if err := json.Unmarshal(content, output.interactor); err != nil {
+					return nil, fmt.Errorf("invalid interactor result of test %d: %w", index+1, err)
+				}
+			}
+			continue
+		}
+		switch ext {
+		case ".stdout":
+			output.stdout = string(content)
+			output.outputTruncated = truncated
+		case ".stderr":
+			output.stderr = string(content)
+		case ".json":
+			if err := json.Unmarshal(content, &output.execution); err != nil {
+				return nil, fmt.Errorf("invalid result of test %d: %w", index+1, err)
+			}
+			finished[index] = true
 		}
+	}
 
-		if strings.HasSuffix(stderrStr, didntCompileError) {
-			return statePtr(proto.SubmissionState_SUBMISSION_STATE_COMPILE_ERROR)
+	for index := range outputs.tests {
+		if !finished[index] {
+			delete(outputs.tests, index)
 		}
 	}
+	return outputs, nil
+}
+
+// readLimited reads up to limit bytes of r and skips the rest, telling whether there was more.
+func readLimited(r io.Reader, limit int64) ([]byte, bool, error) {
+	content, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(content)) > limit {
+		return content[:limit], true, nil
+	}
+	return content, false, nil
+}
+
+// exitStatus splits the status reported by the shell into an exit code and a signal.
+func exitStatus(statusCode int64) (int32, int32) {
+	if statusCode > signalExitBase && statusCode <= signalExitBase+maxSignal {
+		return 0, int32(statusCode - signalExitBase)
+	}
+	return int32(statusCode), 0
+}
+
+const truncatedMarker = "\n... (truncated)"
 
-	return statePtr(proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER)
+// truncate cuts s to at most limit bytes and makes it valid UTF-8, so it can be sent and stored as text.
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return strings.ToValidUTF8(s, "\uFFFD")
+	}
+	return strings.ToValidUTF8(s[:limit], "\uFFFD") + truncatedMarker
 }
 
 func pullImage(ctx context.Context, docker *client.Client, img string) error {
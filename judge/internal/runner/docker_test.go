@@ -3,8 +3,13 @@ package runner
 import (
 	"context"
 	"github.com/stretchr/testify/suite"
+	"maps"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/CT1403-2/Code-Judgement/judge/config"
 	"github.com/CT1403-2/Code-Judgement/proto"
@@ -30,6 +35,7 @@ func (s *DockerRunnerSuite) SetupSuite() {
 		Statement: "Write a program that echos the input.",
 		Input:     &input,
 		Output:    &expectedOutput,
+		InputMode: proto.InputMode_INPUT_MODE_ARGUMENTS,
 		Limitations: &proto.Limitations{
 			Duration: 1000,
 			Memory:   512,
@@ -38,7 +44,8 @@ func (s *DockerRunnerSuite) SetupSuite() {
 
 	s.config = &config.Config{
 		Runner: config.RunnerConfig{
-			Image: "runner:v0.0.7",
+			Image:     "runner:v0.0.9",
+			Languages: config.DefaultLanguages,
 		},
 	}
 }
@@ -65,7 +72,9 @@ func (s *DockerRunnerSuite) TestSuccessfulRun() {
 		s.Failf("Error running submission: %v", err.Error())
 	}
 
-	s.Equal(proto.SubmissionState_SUBMISSION_STATE_OK, *result)
+	s.Equal(proto.SubmissionState_SUBMISSION_STATE_OK, result.State)
+	s.Positive(result.Memory)
+	s.Less(int64(result.WallTime), s.question.Limitations.Duration)
 }
 
 func (s *DockerRunnerSuite) TestFalse() {
@@ -90,7 +99,7 @@ func (s *DockerRunnerSuite) TestFalse() {
 		s.Failf("Error running submission: %v", err.Error())
 	}
 
-	s.Equal(proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER, *result)
+	s.Equal(proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER, result.State)
 }
 
 func (s *DockerRunnerSuite) TestNonCompilable() {
@@ -115,7 +124,61 @@ func (s *DockerRunnerSuite) TestNonCompilable() {
 		s.Failf("Error running submission: %v", err.Error())
 	}
 
-	s.Equal(proto.SubmissionState_SUBMISSION_STATE_COMPILE_ERROR, *result)
+	s.Equal(proto.SubmissionState_SUBMISSION_STATE_COMPILE_ERROR, result.State)
+	s.Contains(result.CompilerOutput, "syntax error")
+}
+
+func (s *DockerRunnerSuite) TestCompileLimits() {
+	code, err := os.ReadFile("test_data/good_code")
+	if err != nil {
+		s.Failf("Failed to read test code file: %v", err.Error())
+	}
+
+	submission := &proto.Submission{
+		Id:         stringPtr("compile-limits-submission"),
+		QuestionId: "q123",
+		Code:       code,
+		State:      statePtr(proto.SubmissionState_SUBMISSION_STATE_JUDGING),
+	}
+
+	ctx := context.Background()
+	result, err := New(s.config).Run(ctx, s.question, submission)
+	if err != nil {
+		s.Failf("Error running submission: %v", err.Error())
+	}
+	s.Equal(proto.SubmissionState_SUBMISSION_STATE_OK, result.State)
+	s.Positive(result.CompileTime)
+
+	// the compile phase has limits of its own, the memory limit of the question does not apply to it
+	question := &proto.Question{
+		Id:        stringPtr("q123"),
+		Title:     "Echo",
+		Input:     s.question.Input,
+		Output:    s.question.Output,
+		InputMode: proto.InputMode_INPUT_MODE_ARGUMENTS,
+		Limitations: &proto.Limitations{
+			Duration: 1000,
+			Memory:   8,
+		},
+	}
+	result, err = New(s.config).Run(ctx, question, submission)
+	if err != nil {
+		s.Failf("Error running submission: %v", err.Error())
+	}
+	s.NotEqual(proto.SubmissionState_SUBMISSION_STATE_COMPILE_ERROR, result.State)
+
+	languages := maps.Clone(config.DefaultLanguages)
+	golang := languages["go"]
+	golang.CompileTimeLimit = 100 * time.Millisecond
+	languages["go"] = golang
+	cfg := *s.config
+	cfg.Runner.Languages = languages
+	result, err = New(&cfg).Run(ctx, s.question, submission)
+	if err != nil {
+		s.Failf("Error running submission: %v", err.Error())
+	}
+	s.Equal(proto.SubmissionState_SUBMISSION_STATE_COMPILE_ERROR, result.State)
+	s.Contains(result.CompilerOutput, "compile time limit exceeded")
 }
 
 func (s *DockerRunnerSuite) TestTimeLimit() {
@@ -140,11 +203,73 @@ func (s *DockerRunnerSuite) TestTimeLimit() {
 		s.Failf("Error running submission: %v", err.Error())
 	}
 
-	s.Equal(proto.SubmissionState_SUBMISSION_STATE_TIME_LIMIT_EXCEEDED, *result)
+	s.Equal(proto.SubmissionState_SUBMISSION_STATE_TIME_LIMIT_EXCEEDED, result.State)
+	s.GreaterOrEqual(int64(result.WallTime), s.question.Limitations.Duration)
+}
+
+func (s *DockerRunnerSuite) TestFakeTimeLimit() {
+	code, err := os.ReadFile("test_data/fake_time_limit_code")
+	if err != nil {
+		s.Failf("Failed to read test code file: %v", err.Error())
+	}
+
+	submission := &proto.Submission{
+		Id:         stringPtr("fake-time-limit-submission"),
+		QuestionId: "q123",
+		Code:       code,
+		State:      statePtr(proto.SubmissionState_SUBMISSION_STATE_JUDGING),
+	}
+
+	runner := New(s.config)
+
+	ctx := context.Background()
+	result, err := runner.Run(ctx, s.question, submission)
+
+	if err != nil {
+		s.Failf("Error running submission: %v", err.Error())
+	}
+
+	s.Equal(proto.SubmissionState_SUBMISSION_STATE_RUNTIME_ERROR, result.State)
+	s.Equal(int32(124), result.ExitCode)
+}
+
+func (s *DockerRunnerSuite) TestPinnedCPUs() {
+	code, err := os.ReadFile("test_data/affinity_python_code")
+	if err != nil {
+		s.Failf("Failed to read test code file: %v", err.Error())
+	}
+
+	question := &proto.Question{
+		Id:        stringPtr("q132"),
+		Title:     "Affinity",
+		TestCases: []*proto.TestCase{{Input: "", Output: "1\n"}},
+		Limitations: &proto.Limitations{
+			Duration: 1000,
+			Memory:   512,
+		},
+	}
+	submission := &proto.Submission{
+		Id:         stringPtr("pinned-cpus-submission"),
+		QuestionId: "q132",
+		Code:       code,
+		Language:   "python",
+		State:      statePtr(proto.SubmissionState_SUBMISSION_STATE_JUDGING),
+	}
+
+	runner := New(s.config)
+
+	ctx := WithCPUs(context.Background(), "0")
+	result, err := runner.Run(ctx, question, submission)
+
+	if err != nil {
+		s.Failf("Error running submission: %v", err.Error())
+	}
+
+	s.Equal(proto.SubmissionState_SUBMISSION_STATE_OK, result.State)
 }
 
 func (s *DockerRunnerSuite) TestMemoryLimit() {
-	code, err := os.ReadFile("test_data/memory_limit_code")
+	code, err := os.ReadFile("test_data/memory_limit_c_code")
 	if err != nil {
 		s.Failf("Failed to read test code file: %v", err.Error())
 	}
@@ -157,9 +282,10 @@ func (s *DockerRunnerSuite) TestMemoryLimit() {
 		Statement: "Write a program that echos the input.",
 		Input:     &input,
 		Output:    &expectedOutput,
+		InputMode: proto.InputMode_INPUT_MODE_ARGUMENTS,
 		Limitations: &proto.Limitations{
 			Duration: 1000,
-			Memory:   8,
+			Memory:   64,
 		},
 	}
 
@@ -167,6 +293,7 @@ func (s *DockerRunnerSuite) TestMemoryLimit() {
 		Id:         stringPtr("memory-limit-submission"),
 		QuestionId: "q123",
 		Code:       code,
+		Language:   "c",
 		State:      statePtr(proto.SubmissionState_SUBMISSION_STATE_JUDGING),
 	}
 	runner := New(s.config)
@@ -178,7 +305,600 @@ func (s *DockerRunnerSuite) TestMemoryLimit() {
 		s.Failf("Error running submission: %v", err.Error())
 	}
 
-	s.Equal(proto.SubmissionState_SUBMISSION_STATE_MEMORY_LIMIT_EXCEEDED, *result)
+	s.Equal(proto.SubmissionState_SUBMISSION_STATE_MEMORY_LIMIT_EXCEEDED, result.State)
+}
+
+func (s *DockerRunnerSuite) TestMultipleTestCases() {
+	code, err := os.ReadFile("test_data/good_code")
+	if err != nil {
+		s.Failf("Failed to read test code file: %v", err.Error())
+	}
+
+	question := &proto.Question{
+		Id:        stringPtr("q124"),
+		Title:     "Echo",
+		Statement: "Write a program that echos the input.",
+		TestCases: []*proto.TestCase{
+			{Input: "first", Output: "first"},
+			{Input: "second test", Output: "second test"},
+			{Input: "third", Output: "fourth"},
+			{Input: "fifth", Output: "fifth"},
+		},
+		InputMode: proto.InputMode_INPUT_MODE_ARGUMENTS,
+		Limitations: &proto.Limitations{
+			Duration: 1000,
+			Memory:   512,
+		},
+	}
+
+	submission := &proto.Submission{
+		Id:         stringPtr("multiple-tests-submission"),
+		QuestionId: "q124",
+		Code:       code,
+		State:      statePtr(proto.SubmissionState_SUBMISSION_STATE_JUDGING),
+	}
+
+	runner := New(s.config)
+
+	ctx := context.Background()
+	result, err := runner.Run(ctx, question, submission)
+
+	if err != nil {
+		s.Failf("Error running submission: %v", err.Error())
+	}
+
+	s.Equal(proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER, result.State)
+	s.Equal(int32(3), result.FailedTest)
+}
+
+func (s *DockerRunnerSuite) TestSubtasks() {
+	code, err := os.ReadFile("test_data/good_code")
+	if err != nil {
+		s.Failf("Failed to read test code file: %v", err.Error())
+	}
+
+	question := &proto.Question{
+		Id:        stringPtr("q130"),
+		Title:     "Echo",
+		Statement: "Write a program that echos the input.",
+		Subtasks: []*proto.Subtask{
+			{Id: stringPtr("1"), Points: 40, ScoringMode: proto.ScoringMode_SCORING_MODE_ALL_OR_NOTHING},
+			{Id: stringPtr("2"), Points: 60, ScoringMode: proto.ScoringMode_SCORING_MODE_PER_TEST},
+		},
+		TestCases: []*proto.TestCase{
+			{Input: "first", Output: "first", SubtaskId: stringPtr("1")},
+			{Input: "second", Output: "second", SubtaskId: stringPtr("1")},
+			{Input: "third", Output: "fourth", SubtaskId: stringPtr("2")},
+			{Input: "fifth", Output: "fifth", SubtaskId: stringPtr("2")},
+		},
+		InputMode: proto.InputMode_INPUT_MODE_ARGUMENTS,
+		Limitations: &proto.Limitations{
+			Duration: 1000,
+			Memory:   512,
+		},
+	}
+
+	submission := &proto.Submission{
+		Id:         stringPtr("subtasks-submission"),
+		QuestionId: "q130",
+		Code:       code,
+		State:      statePtr(proto.SubmissionState_SUBMISSION_STATE_JUDGING),
+	}
+
+	runner := New(s.config)
+
+	ctx := context.Background()
+	result, err := runner.Run(ctx, question, submission)
+
+	if err != nil {
+		s.Failf("Error running submission: %v", err.Error())
+	}
+
+	s.Equal(proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER, result.State)
+	s.Equal(int32(3), result.FailedTest)
+	s.Equal(int32(70), result.Score)
+	s.Equal(int32(100), result.MaxScore)
+}
+
+func (s *DockerRunnerSuite) TestStdinMultiLineInput() {
+	code, err := os.ReadFile("test_data/stdin_code")
+	if err != nil {
+		s.Failf("Failed to read test code file: %v", err.Error())
+	}
+
+	question := &proto.Question{
+		Id:        stringPtr("q125"),
+		Title:     "Cat",
+		Statement: "Write a program that prints its standard input.",
+		TestCases: []*proto.TestCase{
+			{Input: "single line", Output: "single line"},
+			{Input: "first line\nsecond  line\n\tthird line\n", Output: "first line\nsecond  line\n\tthird line\n"},
+			{Input: "", Output: ""},
+		},
+		InputMode: proto.InputMode_INPUT_MODE_STDIN,
+		Limitations: &proto.Limitations{
+			Duration: 1000,
+			Memory:   512,
+		},
+	}
+
+	submission := &proto.Submission{
+		Id:         stringPtr("stdin-multi-line-submission"),
+		QuestionId: "q125",
+		Code:       code,
+		State:      statePtr(proto.SubmissionState_SUBMISSION_STATE_JUDGING),
+	}
+
+	runner := New(s.config)
+
+	ctx := context.Background()
+	result, err := runner.Run(ctx, question, submission)
+
+	if err != nil {
+		s.Failf("Error running submission: %v", err.Error())
+	}
+
+	s.Equal(proto.SubmissionState_SUBMISSION_STATE_OK, result.State)
+}
+
+func (s *DockerRunnerSuite) TestStdinParsedInput() {
+	code, err := os.ReadFile("test_data/stdin_sum_code")
+	if err != nil {
+		s.Failf("Failed to read test code file: %v", err.Error())
+	}
+
+	question := &proto.Question{
+		Id:        stringPtr("q126"),
+		Title:     "Sum",
+		Statement: "Print the sum of each pair of numbers.",
+		TestCases: []*proto.TestCase{
+			{Input: "2\n1 2\n3 4\n", Output: "3\n7\n"},
+			{Input: "3\n10\n20\n-1 1\n0 0", Output: "30\n0\n0\n"},
+		},
+		Limitations: &proto.Limitations{
+			Duration: 1000,
+			Memory:   512,
+		},
+	}
+
+	submission := &proto.Submission{
+		Id:         stringPtr("stdin-sum-submission"),
+		QuestionId: "q126",
+		Code:       code,
+		State:      statePtr(proto.SubmissionState_SUBMISSION_STATE_JUDGING),
+	}
+
+	runner := New(s.config)
+
+	ctx := context.Background()
+	result, err := runner.Run(ctx, question, submission)
+
+	if err != nil {
+		s.Failf("Error running submission: %v", err.Error())
+	}
+
+	s.Equal(proto.SubmissionState_SUBMISSION_STATE_OK, result.State)
+}
+
+func (s *DockerRunnerSuite) TestSuiteFiles() {
+	large := strings.Repeat("0123456789abcdef", 4<<20/16*3) // 12 MB, well over the argument size limit
+
+	testCases := []struct {
+		name     string
+		language string
+		codeFile string
+		test     *proto.TestCase
+	}{
+		{"quotes", "go", "test_data/quotes_code",
+			&proto.TestCase{Input: `it's "quoted" '; exit 1; '$(touch /tmp/pwned)'`, Output: `it's "quoted" '; exit 1; '$(touch /tmp/pwned)'`}},
+		{"binary", "go", "test_data/stdin_code",
+			&proto.TestCase{Input: "\x00\x01\x7f\xff\xfe\r\n\x00", Output: "\x00\x01\x7f\xff\xfe\r\n\x00"}},
+		{"large input", "python", "test_data/length_python_code",
+			&proto.TestCase{Input: large, Output: strconv.Itoa(len(large)) + "\n"}},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			code, err := os.ReadFile(tc.codeFile)
+			if err != nil {
+				s.Failf("Failed to read test code file: %v", err.Error())
+			}
+
+			question := &proto.Question{
+				Id:        stringPtr("q133"),
+				Title:     "Files",
+				TestCases: []*proto.TestCase{tc.test},
+				InputMode: proto.InputMode_INPUT_MODE_STDIN,
+				Limitations: &proto.Limitations{
+					Duration: 2000,
+					Memory:   512,
+				},
+			}
+			submission := &proto.Submission{
+				Id:         stringPtr(filepath.Base(tc.codeFile)),
+				QuestionId: "q133",
+				Code:       code,
+				Language:   tc.language,
+				State:      statePtr(proto.SubmissionState_SUBMISSION_STATE_JUDGING),
+			}
+
+			runner := New(s.config)
+
+			ctx := context.Background()
+			result, err := runner.Run(ctx, question, submission)
+
+			if err != nil {
+				s.Failf("Error running submission: %v", err.Error())
+			}
+
+			s.Equal(proto.SubmissionState_SUBMISSION_STATE_OK, result.State)
+		})
+	}
+}
+
+func (s *DockerRunnerSuite) TestLanguages() {
+	question := &proto.Question{
+		Id:        stringPtr("q127"),
+		Title:     "Sum",
+		Statement: "Print the sum of n numbers.",
+		TestCases: []*proto.TestCase{
+			{Input: "3\n1 2 3\n", Output: "6\n"},
+			{Input: "1\n-5\n", Output: "-5\n"},
+		},
+		Limitations: &proto.Limitations{
+			Duration: 2000,
+			Memory:   512,
+		},
+	}
+
+	testCases := []struct {
+		language string
+		codeFile string
+		expected proto.SubmissionState
+	}{
+		{"c", "test_data/sum_c_code", proto.SubmissionState_SUBMISSION_STATE_OK},
+		{"cpp", "test_data/sum_cpp_code", proto.SubmissionState_SUBMISSION_STATE_OK},
+		{"python", "test_data/sum_python_code", proto.SubmissionState_SUBMISSION_STATE_OK},
+		{"java", "test_data/sum_java_code", proto.SubmissionState_SUBMISSION_STATE_OK},
+		{"cpp", "test_data/non_compilable_cpp_code", proto.SubmissionState_SUBMISSION_STATE_COMPILE_ERROR},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.codeFile, func() {
+			code, err := os.ReadFile(tc.codeFile)
+			if err != nil {
+				s.Failf("Failed to read test code file: %v", err.Error())
+			}
+
+			submission := &proto.Submission{
+				Id:         stringPtr(filepath.Base(tc.codeFile)),
+				QuestionId: "q127",
+				Code:       code,
+				Language:   tc.language,
+				State:      statePtr(proto.SubmissionState_SUBMISSION_STATE_JUDGING),
+			}
+
+			runner := New(s.config)
+
+			ctx := context.Background()
+			result, err := runner.Run(ctx, question, submission)
+
+			if err != nil {
+				s.Failf("Error running submission: %v", err.Error())
+			}
+
+			s.Equal(tc.expected, result.State)
+		})
+	}
+}
+
+func (s *DockerRunnerSuite) TestRuntimeError() {
+	question := &proto.Question{
+		Id:        stringPtr("q129"),
+		Title:     "Inverse",
+		Statement: "Print the integer part of 100/n.",
+		TestCases: []*proto.TestCase{
+			{Input: "1\n", Output: "100\n"},
+			{Input: "0\n", Output: "0\n"},
+		},
+		Limitations: &proto.Limitations{
+			Duration: 2000,
+			Memory:   512,
+		},
+	}
+
+	testCases := []struct {
+		language   string
+		codeFile   string
+		failedTest int32
+		exitCode   int32
+		signal     int32
+	}{
+		{"c", "test_data/divide_by_zero_c_code", 2, 0, 8},
+		{"c", "test_data/segfault_c_code", 1, 0, 11},
+		{"c", "test_data/abort_c_code", 1, 0, 6},
+		{"go", "test_data/panic_code", 1, 2, 0},
+		{"python", "test_data/exit_code_python_code", 1, 3, 0},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.codeFile, func() {
+			code, err := os.ReadFile(tc.codeFile)
+			if err != nil {
+				s.Failf("Failed to read test code file: %v", err.Error())
+			}
+
+			submission := &proto.Submission{
+				Id:         stringPtr(filepath.Base(tc.codeFile)),
+				QuestionId: "q129",
+				Code:       code,
+				Language:   tc.language,
+				State:      statePtr(proto.SubmissionState_SUBMISSION_STATE_JUDGING),
+			}
+
+			runner := New(s.config)
+
+			ctx := context.Background()
+			result, err := runner.Run(ctx, question, submission)
+
+			if err != nil {
+				s.Failf("Error running submission: %v", err.Error())
+			}
+
+			s.Equal(proto.SubmissionState_SUBMISSION_STATE_RUNTIME_ERROR, result.State)
+			s.Equal(tc.failedTest, result.FailedTest)
+			s.Equal(tc.exitCode, result.ExitCode)
+			s.Equal(tc.signal, result.Signal)
+		})
+	}
+}
+
+// TestSandbox runs a malicious program per restriction of the sandbox. The ones that can tell
+// print "restricted" when the restriction held, the others are killed.
+func (s *DockerRunnerSuite) TestSandbox() {
+	question := &proto.Question{
+		Id:        stringPtr("q134"),
+		Title:     "Sandbox",
+		TestCases: []*proto.TestCase{{Input: "", Output: "restricted\n"}},
+		Limitations: &proto.Limitations{
+			Duration: 2000,
+			Memory:   512,
+		},
+	}
+
+	testCases := []struct {
+		language string
+		codeFile string
+		expected proto.SubmissionState
+		signal   int32
+	}{
+		{"python", "test_data/readonly_rootfs_python_code", proto.SubmissionState_SUBMISSION_STATE_OK, 0},
+		{"c", "test_data/fork_bomb_c_code", proto.SubmissionState_SUBMISSION_STATE_OK, 0},
+		{"python", "test_data/capabilities_python_code", proto.SubmissionState_SUBMISSION_STATE_OK, 0},
+		{"python", "test_data/kill_python_code", proto.SubmissionState_SUBMISSION_STATE_OK, 0},
+		{"python", "test_data/no_new_privileges_python_code", proto.SubmissionState_SUBMISSION_STATE_OK, 0},
+		{"python", "test_data/root_user_python_code", proto.SubmissionState_SUBMISSION_STATE_OK, 0},
+		{"python", "test_data/suite_reader_python_code", proto.SubmissionState_SUBMISSION_STATE_OK, 0},
+		{"c", "test_data/ptrace_c_code", proto.SubmissionState_SUBMISSION_STATE_OK, 0},
+		{"c", "test_data/file_size_c_code", proto.SubmissionState_SUBMISSION_STATE_OUTPUT_LIMIT_EXCEEDED, 0},
+		{"c", "test_data/stdout_flood_c_code", proto.SubmissionState_SUBMISSION_STATE_OUTPUT_LIMIT_EXCEEDED, 0},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.codeFile, func() {
+			code, err := os.ReadFile(tc.codeFile)
+			if err != nil {
+				s.Failf("Failed to read test code file: %v", err.Error())
+			}
+
+			submission := &proto.Submission{
+				Id:         stringPtr(filepath.Base(tc.codeFile)),
+				QuestionId: "q134",
+				Code:       code,
+				Language:   tc.language,
+				State:      statePtr(proto.SubmissionState_SUBMISSION_STATE_JUDGING),
+			}
+
+			runner := New(s.config)
+
+			ctx := context.Background()
+			result, err := runner.Run(ctx, question, submission)
+
+			if err != nil {
+				s.Failf("Error running submission: %v", err.Error())
+			}
+
+			s.Equal(tc.expected, result.State)
+			s.Equal(tc.signal, result.Signal)
+		})
+	}
+}
+
+// TestOutputLimit echoes the input of a question allowing 1 KB of output per test.
+func (s *DockerRunnerSuite) TestOutputLimit() {
+	code, err := os.ReadFile("test_data/stdin_code")
+	if err != nil {
+		s.Failf("Failed to read test code file: %v", err.Error())
+	}
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected proto.SubmissionState
+	}{
+		{"under limit", strings.Repeat("a", 1024), proto.SubmissionState_SUBMISSION_STATE_OK},
+		{"over limit", strings.Repeat("a", 1025), proto.SubmissionState_SUBMISSION_STATE_OUTPUT_LIMIT_EXCEEDED},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			question := &proto.Question{
+				Id:        stringPtr("q135"),
+				Title:     "Output Limit",
+				TestCases: []*proto.TestCase{{Input: tc.input, Output: tc.input}},
+				InputMode: proto.InputMode_INPUT_MODE_STDIN,
+				Limitations: &proto.Limitations{
+					Duration: 2000,
+					Memory:   512,
+					Output:   1,
+				},
+			}
+			submission := &proto.Submission{
+				Id:         stringPtr("stdin_code"),
+				QuestionId: "q135",
+				Code:       code,
+				Language:   "go",
+				State:      statePtr(proto.SubmissionState_SUBMISSION_STATE_JUDGING),
+			}
+
+			runner := New(s.config)
+
+			ctx := context.Background()
+			result, err := runner.Run(ctx, question, submission)
+
+			if err != nil {
+				s.Failf("Error running submission: %v", err.Error())
+			}
+
+			s.Equal(tc.expected, result.State)
+		})
+	}
+}
+
+func (s *DockerRunnerSuite) TestInteractive() {
+	interactor, err := os.ReadFile("test_data/guess_interactor")
+	if err != nil {
+		s.Failf("Failed to read interactor file: %v", err.Error())
+	}
+
+	question := &proto.Question{
+		Id:        stringPtr("q131"),
+		Title:     "Guess",
+		Statement: "Guess the number between 1 and 100 in at most 10 queries.",
+		TestCases: []*proto.TestCase{
+			{Input: "37"},
+			{Input: "100"},
+		},
+		Interactor:         interactor,
+		InteractorLanguage: "python",
+		Limitations: &proto.Limitations{
+			Duration: 1000,
+			Memory:   512,
+		},
+	}
+
+	testCases := []struct {
+		codeFile string
+		expected proto.SubmissionState
+	}{
+		{"test_data/guess_code", proto.SubmissionState_SUBMISSION_STATE_OK},
+		{"test_data/guess_wrong_code", proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER},
+		{"test_data/guess_idle_code", proto.SubmissionState_SUBMISSION_STATE_IDLENESS_LIMIT_EXCEEDED},
+		{"test_data/guess_garbage_code", proto.SubmissionState_SUBMISSION_STATE_PROTOCOL_VIOLATION},
+		{"test_data/guess_peek_code", proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.codeFile, func() {
+			code, err := os.ReadFile(tc.codeFile)
+			if err != nil {
+				s.Failf("Failed to read test code file: %v", err.Error())
+			}
+
+			submission := &proto.Submission{
+				Id:         stringPtr(filepath.Base(tc.codeFile)),
+				QuestionId: "q131",
+				Code:       code,
+				Language:   "python",
+				State:      statePtr(proto.SubmissionState_SUBMISSION_STATE_JUDGING),
+			}
+
+			runner := New(s.config)
+
+			ctx := context.Background()
+			result, err := runner.Run(ctx, question, submission)
+
+			if err != nil {
+				s.Failf("Error running submission: %v", err.Error())
+			}
+
+			s.Equal(tc.expected, result.State)
+		})
+	}
+}
+
+func (s *DockerRunnerSuite) TestChecker() {
+	checker, err := os.ReadFile("test_data/permutation_checker")
+	if err != nil {
+		s.Failf("Failed to read checker file: %v", err.Error())
+	}
+	failingChecker, err := os.ReadFile("test_data/failing_checker")
+	if err != nil {
+		s.Failf("Failed to read checker file: %v", err.Error())
+	}
+	partialChecker, err := os.ReadFile("test_data/partial_checker")
+	if err != nil {
+		s.Failf("Failed to read checker file: %v", err.Error())
+	}
+
+	testCases := []struct {
+		name     string
+		codeFile string
+		checker  []byte
+		expected proto.SubmissionState
+		score    int32
+	}{
+		{"accepted-permutation", "test_data/stdin_code", checker, proto.SubmissionState_SUBMISSION_STATE_OK, 100},
+		{"rejected-permutation", "test_data/false_code", checker, proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER, 0},
+		{"failing-checker", "test_data/stdin_code", failingChecker, proto.SubmissionState_SUBMISSION_STATE_FAILED, 0},
+		{"partial-permutation", "test_data/stdin_code", partialChecker, proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER, 50},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			code, err := os.ReadFile(tc.codeFile)
+			if err != nil {
+				s.Failf("Failed to read test code file: %v", err.Error())
+			}
+
+			question := &proto.Question{
+				Id:        stringPtr("q128"),
+				Title:     "Permutation",
+				Statement: "Print any permutation of the given numbers.",
+				TestCases: []*proto.TestCase{
+					{Input: "3 1 2", Output: "1 2 3", SubtaskId: stringPtr("1")},
+				},
+				Subtasks: []*proto.Subtask{
+					{Id: stringPtr("1"), Points: 100, ScoringMode: proto.ScoringMode_SCORING_MODE_PER_TEST},
+				},
+				Checker:         tc.checker,
+				CheckerLanguage: "python",
+				Limitations: &proto.Limitations{
+					Duration: 1000,
+					Memory:   512,
+				},
+			}
+
+			submission := &proto.Submission{
+				Id:         stringPtr(tc.name),
+				QuestionId: "q128",
+				Code:       code,
+				State:      statePtr(proto.SubmissionState_SUBMISSION_STATE_JUDGING),
+			}
+
+			runner := New(s.config)
+
+			ctx := context.Background()
+			result, err := runner.Run(ctx, question, submission)
+
+			if err != nil {
+				s.Failf("Error running submission: %v", err.Error())
+			}
+
+			s.Equal(tc.expected, result.State)
+			s.Equal(tc.score, result.Score)
+		})
+	}
 }
 
 func stringPtr(s string) *string {
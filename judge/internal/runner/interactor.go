@@ -0,0 +1,69 @@
+package runner
+
+import (
+	"github.com/CT1403-2/Code-Judgement/proto"
+)
+
+// Exit codes of an interactor, following the testlib convention.
+const (
+	interactorOK                = 0
+	interactorWrongAnswer       = 1
+	interactorProtocolViolation = 2
+)
+
+// interactorTimeLimit is the least time an interactor gets per test, in milliseconds.
+// It runs next to the program, so it gets more when the question allows more.
+const interactorTimeLimit = 10000
+
+const sigpipe = 13
+
+func isInteractive(question *proto.Question) bool {
+	return len(question.GetInteractor()) > 0
+}
+
+// evaluateInteraction judges a test of an interactive question. A crash of the program
+// is reported before the interactor's verdict, since the interactor usually only sees
+// its output end early.
+func evaluateInteraction(output *testOutput, isOOMKilled bool) proto.SubmissionState {
+	program := output.execution
+	if isOOMKilled && program.Status != 0 {
+		return proto.SubmissionState_SUBMISSION_STATE_MEMORY_LIMIT_EXCEEDED
+	}
+	if program.TimedOut {
+		if isIdle(program) {
+			return proto.SubmissionState_SUBMISSION_STATE_IDLENESS_LIMIT_EXCEEDED
+		}
+		return proto.SubmissionState_SUBMISSION_STATE_TIME_LIMIT_EXCEEDED
+	}
+
+	interactor := output.interactor
+	if interactor == nil || interactor.TimedOut {
+		return proto.SubmissionState_SUBMISSION_STATE_FAILED
+	}
+
+	_, signal := exitStatus(program.Status)
+	if program.Status != 0 && signal != sigpipe {
+		return proto.SubmissionState_SUBMISSION_STATE_RUNTIME_ERROR
+	}
+
+	switch interactor.Status {
+	case interactorOK:
+		if signal == sigpipe {
+			// the program kept writing after the interactor was done with it
+			return proto.SubmissionState_SUBMISSION_STATE_PROTOCOL_VIOLATION
+		}
+		return proto.SubmissionState_SUBMISSION_STATE_OK
+	case interactorWrongAnswer:
+		return proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER
+	case interactorProtocolViolation:
+		return proto.SubmissionState_SUBMISSION_STATE_PROTOCOL_VIOLATION
+	default:
+		return proto.SubmissionState_SUBMISSION_STATE_FAILED
+	}
+}
+
+// isIdle tells whether a program that ran out of time spent most of it waiting rather than computing,
+// usually blocked on reading input the interactor never sends.
+func isIdle(program execution) bool {
+	return (program.UserTime+program.SystemTime)*2 < program.WallTime
+}
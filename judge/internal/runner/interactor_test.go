@@ -0,0 +1,47 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/CT1403-2/Code-Judgement/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateInteraction(t *testing.T) {
+	testCases := []struct {
+		name       string
+		program    execution
+		interactor *execution
+		oomKilled  bool
+		expected   proto.SubmissionState
+	}{
+		{"accepted", execution{}, &execution{Status: interactorOK}, false, proto.SubmissionState_SUBMISSION_STATE_OK},
+		{"wrong answer", execution{}, &execution{Status: interactorWrongAnswer}, false,
+			proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER},
+		{"protocol violation", execution{}, &execution{Status: interactorProtocolViolation}, false,
+			proto.SubmissionState_SUBMISSION_STATE_PROTOCOL_VIOLATION},
+		{"interactor failed", execution{}, &execution{Status: 3}, false, proto.SubmissionState_SUBMISSION_STATE_FAILED},
+		{"interactor timed out", execution{}, &execution{Status: 124, TimedOut: true}, false,
+			proto.SubmissionState_SUBMISSION_STATE_FAILED},
+		{"interactor missing", execution{}, nil, false, proto.SubmissionState_SUBMISSION_STATE_FAILED},
+		{"idle", execution{Status: 124, TimedOut: true, WallTime: 1, UserTime: 0.05}, &execution{Status: 2}, false,
+			proto.SubmissionState_SUBMISSION_STATE_IDLENESS_LIMIT_EXCEEDED},
+		{"busy", execution{Status: 124, TimedOut: true, WallTime: 1, UserTime: 0.9}, &execution{Status: 2}, false,
+			proto.SubmissionState_SUBMISSION_STATE_TIME_LIMIT_EXCEEDED},
+		{"crash before interactor verdict", execution{Status: 139}, &execution{Status: interactorProtocolViolation}, false,
+			proto.SubmissionState_SUBMISSION_STATE_RUNTIME_ERROR},
+		{"writing after the end", execution{Status: 128 + sigpipe}, &execution{Status: interactorOK}, false,
+			proto.SubmissionState_SUBMISSION_STATE_PROTOCOL_VIOLATION},
+		{"writing after rejection", execution{Status: 128 + sigpipe}, &execution{Status: interactorWrongAnswer}, false,
+			proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER},
+		{"out of memory", execution{Status: 137}, &execution{Status: interactorProtocolViolation}, true,
+			proto.SubmissionState_SUBMISSION_STATE_MEMORY_LIMIT_EXCEEDED},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output := &testOutput{execution: tc.program, interactor: tc.interactor}
+			require.Equal(t, tc.expected, evaluateInteraction(output, tc.oomKilled))
+		})
+	}
+}
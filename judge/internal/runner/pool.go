@@ -0,0 +1,335 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	resetScript = "./reset.sh"
+
+	// poolLabel marks the containers of a judge, so that the ones left behind by a crash are removed on start.
+	poolLabel = "code-judgement.judge"
+
+	resetTimeout      = 30 * time.Second
+	execPollInterval  = 10 * time.Millisecond
+	containerCPUQuota = 100000 // one CPU per container
+)
+
+// poolKey identifies interchangeable containers. Languages sharing an image share its containers.
+type poolKey struct {
+	image string
+	cpus  string
+}
+
+// containerPool keeps started containers idling on sleep, so that a run only has to exec its script.
+// A container is reset after each run and returned to the pool, or removed when the run left it
+// in an unknown state, such as after an OOM kill or an abort. Runs beyond the size of the pool
+// start containers of their own, removed after the run.
+type containerPool struct {
+	size  int // containers kept per key, idle or in use
+	judge string
+
+	clientMu sync.Mutex
+	docker   *client.Client
+
+	mu      sync.Mutex
+	idle    map[poolKey][]string
+	inUse   map[poolKey]int
+	filling map[poolKey]int
+	closed  bool
+	// pending counts the containers being started, reset or removed in the background
+	pending sync.WaitGroup
+}
+
+func newContainerPool(size int, judge string) *containerPool {
+	return &containerPool{
+		size:    size,
+		judge:   judge,
+		idle:    make(map[poolKey][]string),
+		inUse:   make(map[poolKey]int),
+		filling: make(map[poolKey]int),
+	}
+}
+
+func (p *containerPool) client(ctx context.Context) (*client.Client, error) {
+	p.clientMu.Lock()
+	defer p.clientMu.Unlock()
+	if p.docker == nil {
+		docker, err := createDockerClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.docker = docker
+	}
+	return p.docker, nil
+}
+
+// warm removes the containers left behind by a previous run of the judge and fills the pool for keys.
+func (p *containerPool) warm(ctx context.Context, keys []poolKey) error {
+	docker, err := p.client(ctx)
+	if err != nil {
+		return err
+	}
+	leftovers, err := docker.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", poolLabel+"="+p.judge)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %w", err)
+	}
+	for _, leftover := range leftovers {
+		p.remove(leftover.ID)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, key := range keys {
+		p.fillLocked(key)
+	}
+	return nil
+}
+
+// acquire takes an idle container of key, starting one if there is none.
+func (p *containerPool) acquire(ctx context.Context, key poolKey) (string, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return "", errors.New("container pool is closed")
+	}
+	p.inUse[key]++
+	if ids := p.idle[key]; len(ids) > 0 {
+		id := ids[len(ids)-1]
+		p.idle[key] = ids[:len(ids)-1]
+		p.mu.Unlock()
+		return id, nil
+	}
+	p.mu.Unlock()
+
+	id, err := p.start(ctx, key)
+	if err != nil {
+		p.mu.Lock()
+		p.inUse[key]--
+		p.mu.Unlock()
+	}
+	return id, err
+}
+
+// release resets a container in the background and returns it to the pool. It is removed instead
+// if it is not reusable or the pool is full, and replaced if the pool is short of containers.
+func (p *containerPool) release(key poolKey, containerID string, reusable bool) {
+	p.pending.Add(1)
+	go func() {
+		defer p.pending.Done()
+		if reusable && p.size > 0 {
+			ctx, cancel := context.WithTimeout(context.Background(), resetTimeout)
+			defer cancel()
+			if err := p.reset(ctx, containerID); err != nil {
+				logrus.WithError(err).WithField("container_id", containerID).Warn("Failed to reset container")
+				reusable = false
+			}
+		}
+
+		p.mu.Lock()
+		p.inUse[key]--
+		if reusable && p.putLocked(key, containerID) {
+			p.mu.Unlock()
+			return
+		}
+		p.fillLocked(key)
+		p.mu.Unlock()
+		p.remove(containerID)
+	}()
+}
+
+// fillLocked starts containers in the background until the pool has size containers of key. p.mu must be held.
+func (p *containerPool) fillLocked(key poolKey) {
+	for ; p.countLocked(key) < p.size && !p.closed; p.filling[key]++ {
+		p.pending.Add(1)
+		go func() {
+			defer p.pending.Done()
+			id, err := p.start(context.Background(), key)
+			if err != nil {
+				logrus.WithError(err).WithField("image", key.image).Warn("Failed to warm container")
+			}
+
+			p.mu.Lock()
+			p.filling[key]--
+			if err != nil || p.putLocked(key, id) {
+				p.mu.Unlock()
+				return
+			}
+			p.mu.Unlock()
+			p.remove(id)
+		}()
+	}
+}
+
+// putLocked returns a container to the pool, false if the pool is closed or full. p.mu must be held.
+func (p *containerPool) putLocked(key poolKey, containerID string) bool {
+	if p.closed || p.countLocked(key) >= p.size {
+		return false
+	}
+	p.idle[key] = append(p.idle[key], containerID)
+	return true
+}
+
+func (p *containerPool) countLocked(key poolKey) int {
+	return len(p.idle[key]) + p.inUse[key] + p.filling[key]
+}
+
+// start creates and starts a sandbox container idling until runs are executed in it, reset once
+// to lay out its empty work directories. Memory is limited per run, the other limits hold for the
+// life of the container.
+func (p *containerPool) start(ctx context.Context, key poolKey) (string, error) {
+	docker, err := p.client(ctx)
+	if err != nil {
+		return "", err
+	}
+	containerConfig := &container.Config{
+		Image:           key.image,
+		Cmd:             []string{"sleep", "infinity"},
+		User:            sandboxUser,
+		Tty:             false,
+		NetworkDisabled: true,
+		Labels:          map[string]string{poolLabel: p.judge},
+	}
+	hostConfig, err := sandboxHostConfig(key.cpus)
+	if err != nil {
+		return "", err
+	}
+	resp, err := docker.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to create container: %w", err)
+	}
+	if err := docker.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		p.remove(resp.ID)
+		return "", fmt.Errorf("failed to start container: %w", err)
+	}
+	if err := p.reset(ctx, resp.ID); err != nil {
+		p.remove(resp.ID)
+		return "", fmt.Errorf("failed to prepare container: %w", err)
+	}
+	logrus.WithField("container_id", resp.ID).Debug("Container started")
+	return resp.ID, nil
+}
+
+// reset kills what a run left running and removes the files it wrote, see reset.sh.
+func (p *containerPool) reset(ctx context.Context, containerID string) error {
+	docker, err := p.client(ctx)
+	if err != nil {
+		return err
+	}
+	status, _, stderr, err := execute(ctx, docker, containerID, container.ExecOptions{Cmd: []string{resetScript}}, nil)
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return fmt.Errorf("reset exited with status %d: %s", status, stderr)
+	}
+	return nil
+}
+
+func (p *containerPool) remove(containerID string) {
+	logger := logrus.WithField("container_id", containerID)
+	docker, err := p.client(context.Background())
+	if err != nil {
+		logger.WithError(err).Warn("Failed to remove container")
+		return
+	}
+	removeOptions := container.RemoveOptions{
+		RemoveVolumes: true,
+		Force:         true,
+	}
+	if err := docker.ContainerRemove(context.Background(), containerID, removeOptions); err != nil {
+		logger.WithError(err).Warn("Failed to remove container")
+	} else {
+		logger.Debug("Container removed")
+	}
+}
+
+// close removes every container of the pool once the ones in the background are settled.
+func (p *containerPool) close() error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.pending.Wait()
+
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = make(map[poolKey][]string)
+	p.mu.Unlock()
+	for _, ids := range idle {
+		for _, id := range ids {
+			p.remove(id)
+		}
+	}
+
+	p.clientMu.Lock()
+	defer p.clientMu.Unlock()
+	if p.docker == nil {
+		return nil
+	}
+	return p.docker.Close()
+}
+
+// execute runs a command in a started container and waits for it to exit, returning its
+// exit status and output. The command reads stdin if it is not nil.
+func execute(ctx context.Context, docker *client.Client, containerID string, options container.ExecOptions,
+	stdin io.Reader) (int, string, string, error) {
+	options.AttachStdin = stdin != nil
+	options.AttachStdout = true
+	options.AttachStderr = true
+	exec, err := docker.ContainerExecCreate(ctx, containerID, options)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("failed to create exec: %w", err)
+	}
+	resp, err := docker.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return 0, "", "", fmt.Errorf("failed to start exec: %w", err)
+	}
+	defer resp.Close()
+	// the attached connection ignores ctx once established, closing it stops reading when ctx is done
+	stop := context.AfterFunc(ctx, resp.Close)
+	defer stop()
+	if stdin != nil {
+		go func() {
+			// a failed write is seen as the command failing, closing tells it that the input ended
+			_, _ = io.Copy(resp.Conn, stdin)
+			_ = resp.CloseWrite()
+		}()
+	}
+
+	stdout, stderr, err := getContainerLogs(resp.Reader)
+	if ctx.Err() != nil {
+		return 0, "", "", ctx.Err()
+	}
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	// the output ends slightly before the exit status is recorded
+	for {
+		inspect, err := docker.ContainerExecInspect(ctx, exec.ID)
+		if err != nil {
+			return 0, "", "", fmt.Errorf("failed to inspect exec: %w", err)
+		}
+		if !inspect.Running {
+			return inspect.ExitCode, stdout, stderr, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, "", "", ctx.Err()
+		case <-time.After(execPollInterval):
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerPool(t *testing.T) {
+	ctx := context.Background()
+	pool := newContainerPool(1, "test-container-pool")
+	defer func() {
+		require.NoError(t, pool.close())
+	}()
+	docker, err := pool.client(ctx)
+	require.NoError(t, err)
+	key := poolKey{image: "runner:v0.0.9"}
+
+	t.Run("reused after reset", func(t *testing.T) {
+		id, err := pool.acquire(ctx, key)
+		require.NoError(t, err)
+		status, stdout, _, err := execute(ctx, docker, id, container.ExecOptions{
+			Cmd: []string{"/bin/sh", "-c", "touch /tmp/leftover app/leftover && { sleep 1000 > /dev/null 2>&1 & echo $!; }"},
+		}, nil)
+		require.NoError(t, err)
+		require.Equal(t, 0, status)
+		pid := strings.TrimSpace(stdout)
+
+		pool.release(key, id, true)
+		pool.pending.Wait()
+
+		reused, err := pool.acquire(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, id, reused)
+		defer pool.release(key, reused, true)
+
+		status, _, _, err = execute(ctx, docker, reused, container.ExecOptions{
+			Cmd: []string{"/bin/sh", "-c", "! kill -0 " + pid + " && ! ls /tmp/leftover app/leftover && test -f app/go.mod"},
+		}, nil)
+		require.NoError(t, err)
+		require.Equal(t, 0, status)
+	})
+
+	t.Run("not reusable", func(t *testing.T) {
+		pool.pending.Wait()
+		id, err := pool.acquire(ctx, key)
+		require.NoError(t, err)
+
+		pool.release(key, id, false)
+		pool.pending.Wait()
+
+		_, err = docker.ContainerInspect(ctx, id)
+		require.Error(t, err)
+		// the removed container is replaced
+		pool.mu.Lock()
+		require.Len(t, pool.idle[key], 1)
+		pool.mu.Unlock()
+	})
+
+	t.Run("beyond size", func(t *testing.T) {
+		pool.pending.Wait()
+		first, err := pool.acquire(ctx, key)
+		require.NoError(t, err)
+		second, err := pool.acquire(ctx, key)
+		require.NoError(t, err)
+		require.NotEqual(t, first, second)
+
+		pool.release(key, first, true)
+		pool.release(key, second, true)
+		pool.pending.Wait()
+
+		pool.mu.Lock()
+		require.Len(t, pool.idle[key], 1)
+		require.Zero(t, pool.inUse[key])
+		pool.mu.Unlock()
+	})
+}
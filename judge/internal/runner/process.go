@@ -0,0 +1,733 @@
+//go:build linux
+
+package runner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/CT1403-2/Code-Judgement/judge/config"
+	"github.com/CT1403-2/Code-Judgement/proto"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// sandboxKillDelay is how long a sandbox gets past its time limit to report before its cgroup is killed.
+	sandboxKillDelay   = 5 * time.Second
+	cgroupRemoveWait   = 5 * time.Second
+	sandboxControllers = "+cpu +cpuset +memory +pids"
+)
+
+// runTmpfsSizes bound the writable directories of a run, as the tmpfs of a container do.
+var runTmpfsSizes = map[string]string{"app": "512m", "home": "512m", "interactor": "64m"}
+
+// processRunner runs every program on the host in namespaces and a cgroup of its own, see sandboxInit.
+type processRunner struct {
+	config *config.Config
+	uids   *uidPool
+	setup  func() error
+}
+
+func newProcessRunner(cfg *config.Config) Runner {
+	return &processRunner{
+		config: cfg,
+		uids:   newUIDPool(cfg.Runner.Process.FirstUID, cfg.Runner.Process.UIDs),
+		setup: sync.OnceValue(func() error {
+			if err := os.MkdirAll(cfg.Runner.Process.WorkDir, 0o700); err != nil {
+				return fmt.Errorf("failed to create work directory: %w", err)
+			}
+			if err := os.Chmod(cfg.Runner.Process.WorkDir, 0o700); err != nil {
+				return fmt.Errorf("failed to create work directory: %w", err)
+			}
+			return enableControllers(cfg.Runner.Process.Cgroup)
+		}),
+	}
+}
+
+func (p *processRunner) Run(ctx context.Context, question *proto.Question, submission *proto.Submission) (*Result, error) {
+	if err := p.setup(); err != nil {
+		return nil, err
+	}
+	return runSubmission(ctx, p.config, p, question, submission)
+}
+
+func (p *processRunner) Close() error {
+	return nil
+}
+
+// processRun is the directory, users and cgroups of a suite.
+type processRun struct {
+	config        config.ProcessConfig
+	id            string // names the cgroups of the run
+	dir           string
+	cpus          string
+	readOnly      []string
+	uid           int // of the program, the checker and their compilation
+	interactorUID int
+	uids          *uidPool
+}
+
+// sandbox is a program to run in its own namespaces and cgroup.
+type sandbox struct {
+	name                  string // names the cgroup of the program, unique in the run
+	uid                   int
+	mounts                []sandboxMount
+	dir                   string // in the sandbox, as is home
+	home                  string
+	command               []string
+	stdin, stdout, stderr *os.File // nil for /dev/null
+	phase                 string
+	timeLimit             time.Duration
+	memory                int64 // mega bytes
+}
+
+// runSuite runs script over the suite the way run.sh and check.sh do in a container.
+func (p *processRunner) runSuite(ctx context.Context, logger *logrus.Entry, script string,
+	limitations *proto.Limitations, language config.LanguageConfig, interactor *config.LanguageConfig,
+	suite SuiteConfig) (*suiteOutput, bool, error) {
+	run, err := newProcessRun(p.config.Runner.Process, p.uids, cpusFromContext(ctx), suite)
+	if err != nil {
+		return nil, false, err
+	}
+	defer run.remove()
+	logger = logger.WithField("run_dir", run.dir)
+	logger.Info("Run directory created")
+
+	outputs := &suiteOutput{tests: make(map[int]*testOutput)}
+	if err := run.writeFile(run.path("app", language.SourceFile), suite.Code, run.uid); err != nil {
+		return nil, false, err
+	}
+	outputs.compile, outputs.compileOutput, outputs.compileOutputTruncated, err = run.compile(ctx,
+		run.programSandbox("compile"), language)
+	if err != nil || outputs.compileFailed() {
+		return outputs, false, err
+	}
+
+	if interactor != nil {
+		if err := run.writeFile(run.path("interactor", interactor.SourceFile), suite.Interactor, run.interactorUID); err != nil {
+			return nil, false, err
+		}
+		outputs.interactorCompile, _, _, err = run.compile(ctx, run.interactorSandbox("interactor-compile"), *interactor)
+		if err != nil || outputs.interactorCompileFailed() {
+			return outputs, false, err
+		}
+	}
+
+	limit := outputLimit(limitations)
+	for i, test := range suite.Tests {
+		var output *testOutput
+		switch {
+		case script == checkScript:
+			output, err = run.check(ctx, i, limitations, language)
+		case interactor != nil:
+			output, err = run.interact(ctx, i, test, limitations, language, *interactor)
+		default:
+			output, err = run.test(ctx, i, test, suite.Arguments, limitations, language)
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if err := run.readOutput(i, output, limit); err != nil {
+			return nil, false, err
+		}
+		outputs.tests[i] = output
+	}
+	logger.Info("Suite execution completed")
+	return outputs, false, nil
+}
+
+func newProcessRun(cfg config.ProcessConfig, uids *uidPool, cpus string, suite SuiteConfig) (*processRun, error) {
+	readOnly, err := readOnlyPaths(cfg.ReadOnlyPaths)
+	if err != nil {
+		return nil, err
+	}
+	uid, err := uids.acquire()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(cfg.WorkDir, "run-")
+	if err != nil {
+		uids.release(uid)
+		return nil, fmt.Errorf("failed to create run directory: %w", err)
+	}
+	run := &processRun{
+		config:        cfg,
+		id:            filepath.Base(dir),
+		dir:           dir,
+		cpus:          cpus,
+		readOnly:      readOnly,
+		uid:           uid,
+		interactorUID: uid + 1,
+		uids:          uids,
+	}
+	if err := run.prepare(suite); err != nil {
+		run.remove()
+		return nil, err
+	}
+	return run, nil
+}
+
+func readOnlyPaths(patterns []string) ([]string, error) {
+	var paths []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid read-only path %s: %w", pattern, err)
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+func (r *processRun) prepare(suite SuiteConfig) error {
+	err := errors.Join(
+		os.Chmod(r.dir, 0o700),
+		os.Mkdir(r.path("root"), 0o700),
+		os.Mkdir(r.path("results"), 0o700),
+		os.MkdirAll(r.path("suite", "tests"), 0o755),
+	)
+	for dir, uid := range r.owners() {
+		if mkdirErr := os.Mkdir(r.path(dir), 0o700); mkdirErr != nil {
+			err = errors.Join(err, mkdirErr)
+			continue
+		}
+		options := fmt.Sprintf("size=%s,uid=%d,gid=%d,mode=0700", runTmpfsSizes[dir], uid, uid)
+		if mountErr := unix.Mount("tmpfs", r.path(dir), "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, options); mountErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to mount %s: %w", dir, mountErr))
+		}
+	}
+	err = errors.Join(err,
+		r.writeFile(r.path("app", "go.mod"), []byte("module main\n\ngo 1.24\n"), r.uid),
+		r.writeFile(r.path("interactor", "go.mod"), []byte("module main\n\ngo 1.24\n"), r.interactorUID),
+	)
+	for i, test := range suite.Tests {
+		err = errors.Join(err,
+			os.WriteFile(r.path("suite", "tests", fmt.Sprintf("%d.input", i)), []byte(test.Input), 0o644),
+			os.WriteFile(r.path("suite", "tests", fmt.Sprintf("%d.output", i)), []byte(test.Output), 0o644),
+			os.WriteFile(r.path("suite", "tests", fmt.Sprintf("%d.answer", i)), []byte(test.Answer), 0o644),
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to prepare run directory: %w", err)
+	}
+	return nil
+}
+
+func (r *processRun) owners() map[string]int {
+	return map[string]int{"app": r.uid, "home": r.uid, "interactor": r.interactorUID}
+}
+
+func (r *processRun) path(elem ...string) string {
+	return filepath.Join(append([]string{r.dir}, elem...)...)
+}
+
+func (r *processRun) writeFile(name string, content []byte, uid int) error {
+	if err := os.WriteFile(name, content, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(name), err)
+	}
+	if err := os.Chown(name, uid, uid); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(name), err)
+	}
+	return nil
+}
+
+// remove keeps the users of the run if files of theirs may be left.
+func (r *processRun) remove() {
+	for dir := range r.owners() {
+		err := unix.Unmount(r.path(dir), unix.MNT_DETACH)
+		if err != nil && !errors.Is(err, unix.EINVAL) && !errors.Is(err, unix.ENOENT) {
+			logrus.WithError(err).WithField("run_dir", r.dir).Warnf("Failed to unmount %s", dir)
+			return
+		}
+	}
+	if err := os.RemoveAll(r.dir); err != nil {
+		logrus.WithError(err).WithField("run_dir", r.dir).Warn("Failed to remove run directory")
+		return
+	}
+	r.uids.release(r.uid)
+}
+
+func (r *processRun) programSandbox(name string) sandbox {
+	return sandbox{
+		name: name,
+		uid:  r.uid,
+		mounts: []sandboxMount{
+			{Source: r.path("app"), Target: "/app", Writable: true},
+			{Source: r.path("home"), Target: "/home", Writable: true},
+		},
+		dir:  "/app",
+		home: "/home",
+	}
+}
+
+func (r *processRun) interactorSandbox(name string) sandbox {
+	return sandbox{
+		name:   name,
+		uid:    r.interactorUID,
+		mounts: []sandboxMount{{Source: r.path("interactor"), Target: "/interactor", Writable: true}},
+		dir:    "/interactor",
+		home:   "/interactor",
+	}
+}
+
+// compile returns nil if the language has no compile step.
+func (r *processRun) compile(ctx context.Context, sb sandbox,
+	language config.LanguageConfig) (*execution, string, bool, error) {
+	if language.CompileCommand == "" {
+		return nil, "", false, nil
+	}
+	output, err := r.create(sb.name + ".output")
+	if err != nil {
+		return nil, "", false, err
+	}
+	sb.command = []string{"/bin/sh", "-c", language.CompileCommand}
+	// stdout and stderr share the file and its offset, as with 2>&1
+	sb.stdout, sb.stderr = output, output
+	sb.phase = "compile"
+	sb.timeLimit = language.CompileTimeLimit
+	sb.memory = language.CompileMemoryLimit
+	compile, err := r.execute(ctx, sb)
+	if err != nil {
+		return nil, "", false, err
+	}
+	content, truncated, err := r.read(sb.name+".output", language.CompileOutputLimit*1024)
+	if err != nil {
+		return nil, "", false, err
+	}
+	return compile, string(content), truncated, nil
+}
+
+func (r *processRun) test(ctx context.Context, i int, test SuiteTest, arguments bool, limitations *proto.Limitations,
+	language config.LanguageConfig) (*testOutput, error) {
+	command := strings.Fields(language.RunCommand)
+	var stdin *os.File
+	if arguments {
+		command = append(command, splitArguments(test.Input)...)
+	} else {
+		var err error
+		stdin, err = os.Open(r.path("suite", "tests", fmt.Sprintf("%d.input", i)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open input of test %d: %w", i+1, err)
+		}
+	}
+	stdout, stderr, err := r.createOutputs(strconv.Itoa(i), stdin)
+	if err != nil {
+		return nil, err
+	}
+	sb := r.programSandbox(strconv.Itoa(i))
+	sb.command = command
+	sb.stdin, sb.stdout, sb.stderr = stdin, stdout, stderr
+	sb.phase = "run"
+	sb.timeLimit = time.Duration(limitations.GetDuration()) * time.Millisecond
+	sb.memory = limitations.GetMemory()
+	program, err := r.execute(ctx, sb)
+	if err != nil {
+		return nil, err
+	}
+	return &testOutput{execution: *program}, nil
+}
+
+// check passes the checker the paths of the input, the contestant output and the answer.
+func (r *processRun) check(ctx context.Context, i int, limitations *proto.Limitations,
+	language config.LanguageConfig) (*testOutput, error) {
+	command := strings.Fields(language.RunCommand)
+	for _, ext := range []string{"input", "output", "answer"} {
+		command = append(command, fmt.Sprintf("/tests/%d.%s", i, ext))
+	}
+	stdout, stderr, err := r.createOutputs(strconv.Itoa(i), nil)
+	if err != nil {
+		return nil, err
+	}
+	sb := r.programSandbox(strconv.Itoa(i))
+	sb.mounts = append(sb.mounts, sandboxMount{Source: r.path("suite", "tests"), Target: "/tests"})
+	sb.command = command
+	sb.stdout, sb.stderr = stdout, stderr
+	sb.phase = "check"
+	sb.timeLimit = time.Duration(limitations.GetDuration()) * time.Millisecond
+	sb.memory = limitations.GetMemory()
+	checker, err := r.execute(ctx, sb)
+	if err != nil {
+		return nil, err
+	}
+	return &testOutput{execution: *checker}, nil
+}
+
+// interact runs the program and the interactor connected by pipes.
+func (r *processRun) interact(ctx context.Context, i int, test SuiteTest, limitations *proto.Limitations,
+	language, interactor config.LanguageConfig) (*testOutput, error) {
+	if err := r.writeFile(r.path("interactor", "input"), []byte(test.Input), r.interactorUID); err != nil {
+		return nil, err
+	}
+	toProgram, fromInteractor, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pipe: %w", err)
+	}
+	toInteractor, fromProgram, err := os.Pipe()
+	if err != nil {
+		closeFiles(toProgram, fromInteractor)
+		return nil, fmt.Errorf("failed to create pipe: %w", err)
+	}
+	interactorStderr, err := r.create(fmt.Sprintf("%s%d.stderr", interactorPrefix, i))
+	if err != nil {
+		closeFiles(toProgram, fromInteractor, toInteractor, fromProgram)
+		return nil, err
+	}
+	stderr, err := r.create(fmt.Sprintf("%d.stderr", i))
+	if err != nil {
+		closeFiles(toProgram, fromInteractor, toInteractor, fromProgram, interactorStderr)
+		return nil, err
+	}
+
+	var (
+		wg             sync.WaitGroup
+		interactorRun  *execution
+		interactorErr  error
+		interactorTime = max(limitations.GetDuration(), interactorTimeLimit)
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sb := r.interactorSandbox(fmt.Sprintf("%s%d", interactorPrefix, i))
+		sb.command = append(strings.Fields(interactor.RunCommand), "input", "log")
+		sb.stdin, sb.stdout, sb.stderr = toInteractor, fromInteractor, interactorStderr
+		sb.phase = "interact"
+		sb.timeLimit = time.Duration(interactorTime) * time.Millisecond
+		sb.memory = max(limitations.GetMemory(), checkerMemoryLimit)
+		interactorRun, interactorErr = r.execute(ctx, sb)
+	}()
+	sb := r.programSandbox(strconv.Itoa(i))
+	sb.command = strings.Fields(language.RunCommand)
+	sb.stdin, sb.stdout, sb.stderr = toProgram, fromProgram, stderr
+	sb.phase = "run"
+	sb.timeLimit = time.Duration(limitations.GetDuration()) * time.Millisecond
+	sb.memory = limitations.GetMemory()
+	program, err := r.execute(ctx, sb)
+	wg.Wait()
+	if err := errors.Join(err, interactorErr); err != nil {
+		return nil, err
+	}
+	return &testOutput{execution: *program, interactor: interactorRun}, nil
+}
+
+func (r *processRun) createOutputs(name string, stdin *os.File) (*os.File, *os.File, error) {
+	stdout, err := r.create(name + ".stdout")
+	if err != nil {
+		closeFiles(stdin)
+		return nil, nil, err
+	}
+	stderr, err := r.create(name + ".stderr")
+	if err != nil {
+		closeFiles(stdin, stdout)
+		return nil, nil, err
+	}
+	return stdout, stderr, nil
+}
+
+func (r *processRun) create(name string) (*os.File, error) {
+	file, err := os.Create(r.path("results", name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	return file, nil
+}
+
+func (r *processRun) read(name string, limit int64) ([]byte, bool, error) {
+	file, err := os.Open(r.path("results", name))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	defer file.Close()
+	content, truncated, err := readLimited(file, limit)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return content, truncated, nil
+}
+
+func (r *processRun) readOutput(i int, output *testOutput, outputLimit int64) error {
+	if output.interactor == nil {
+		stdout, truncated, err := r.read(fmt.Sprintf("%d.stdout", i), outputLimit)
+		if err != nil {
+			return err
+		}
+		output.stdout = string(stdout)
+		output.outputTruncated = truncated
+	}
+	stderr, _, err := r.read(fmt.Sprintf("%d.stderr", i), maxContainerLogs)
+	if err != nil {
+		return err
+	}
+	output.stderr = string(stderr)
+	return nil
+}
+
+// execute kills the cgroup of a sandbox that overruns its time limit by sandboxKillDelay.
+func (r *processRun) execute(ctx context.Context, sb sandbox) (*execution, error) {
+	defer closeFiles(sb.stdin, sb.stdout, sb.stderr)
+	cgroup := filepath.Join(r.config.Cgroup, r.id+"-"+sb.name)
+	if err := createCgroup(cgroup, sb.memory, r.cpus); err != nil {
+		return nil, err
+	}
+	defer removeCgroup(cgroup)
+	cgroupDir, err := os.Open(cgroup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cgroup: %w", err)
+	}
+	defer cgroupDir.Close()
+
+	spec, err := json.Marshal(sandboxSpec{
+		Root:      r.path("root"),
+		ReadOnly:  r.readOnly,
+		Mounts:    sb.mounts,
+		Dir:       sb.dir,
+		Command:   sb.command,
+		Env:       []string{"PATH=" + os.Getenv("PATH"), "HOME=" + sb.home, "LANG=C.UTF-8"},
+		UID:       sb.uid,
+		GID:       sb.uid,
+		Phase:     sb.phase,
+		TimeLimit: sb.timeLimit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal sandbox: %w", err)
+	}
+	reportReader, reportWriter, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pipe: %w", err)
+	}
+	defer reportReader.Close()
+
+	cmd := &exec.Cmd{
+		Path:       "/proc/self/exe",
+		Args:       []string{sandboxInitArg},
+		Env:        []string{sandboxSpecEnv + "=" + string(spec), "PATH=" + os.Getenv("PATH")},
+		ExtraFiles: []*os.File{reportWriter},
+		SysProcAttr: &syscall.SysProcAttr{
+			Cloneflags: syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNET |
+				syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+			UseCgroupFD: true,
+			CgroupFD:    int(cgroupDir.Fd()),
+		},
+	}
+	// a nil *os.File would be taken for a reader to copy from
+	if sb.stdin != nil {
+		cmd.Stdin = sb.stdin
+	}
+	if sb.stdout != nil {
+		cmd.Stdout = sb.stdout
+	}
+	if sb.stderr != nil {
+		cmd.Stderr = sb.stderr
+	}
+	err = cmd.Start()
+	// the program holds its own copies, the ends of pipes must be closed here for it to see them end
+	reportWriter.Close()
+	closeFiles(sb.stdin, sb.stdout, sb.stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start sandbox: %w", err)
+	}
+
+	killCtx, cancel := context.WithTimeout(ctx, sb.timeLimit+sandboxKillDelay)
+	defer cancel()
+	stop := context.AfterFunc(killCtx, func() {
+		_ = writeCgroupFile(cgroup, "cgroup.kill", "1")
+	})
+	defer stop()
+
+	content, _, err := readLimited(reportReader, maxContainerLogs)
+	waitErr := cmd.Wait()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sandbox report: %w", err)
+	}
+	program, err := decodeReport(sb.name, content, waitErr)
+	if err != nil {
+		return nil, err
+	}
+	program.OOMKilled, err = oomKilled(cgroup)
+	if err != nil {
+		return nil, err
+	}
+	return program, nil
+}
+
+// decodeReport uses waitErr if the sandbox reported nothing.
+func decodeReport(name string, content []byte, waitErr error) (*execution, error) {
+	var report sandboxReport
+	if err := json.Unmarshal(content, &report); err != nil {
+		return nil, fmt.Errorf("sandbox of %s ended without a report: %v", name, waitErr)
+	}
+	if report.Error != "" {
+		return nil, fmt.Errorf("failed to run sandbox of %s: %s", name, report.Error)
+	}
+	return &report.execution, nil
+}
+
+// uidPool hands out two consecutive ids a run, so that no run shares a user with another.
+type uidPool struct {
+	mu    sync.Mutex
+	first int
+	count int // of pairs
+	used  map[int]bool
+}
+
+func newUIDPool(first, uids int) *uidPool {
+	return &uidPool{first: first, count: uids / 2, used: make(map[int]bool)}
+}
+
+func (p *uidPool) acquire() (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range p.count {
+		uid := p.first + 2*i
+		if !p.used[uid] {
+			p.used[uid] = true
+			return uid, nil
+		}
+	}
+	return 0, errors.New("no free user to run as, are there more runs than runner.process.uids allow?")
+}
+
+func (p *uidPool) release(uid int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.used, uid)
+}
+
+func enableControllers(cgroup string) error {
+	if err := os.MkdirAll(cgroup, 0o755); err != nil {
+		return fmt.Errorf("failed to create cgroup: %w", err)
+	}
+	if err := writeCgroupFile(cgroup, "cgroup.subtree_control", sandboxControllers); err != nil {
+		return fmt.Errorf("failed to enable cgroup controllers, are they enabled in the parent of %s? %w", cgroup, err)
+	}
+	return nil
+}
+
+// createCgroup leaves memory unlimited without a limit, as Docker does.
+func createCgroup(cgroup string, memory int64, cpus string) error {
+	if err := os.Mkdir(cgroup, 0o755); err != nil {
+		return fmt.Errorf("failed to create cgroup: %w", err)
+	}
+	memoryMax := "max"
+	if memory > 0 {
+		memoryMax = strconv.FormatInt(memory*1024*1024, 10)
+	}
+	err := errors.Join(
+		writeCgroupFile(cgroup, "memory.max", memoryMax),
+		writeCgroupFile(cgroup, "pids.max", strconv.Itoa(pidsLimit)),
+		writeCgroupFile(cgroup, "cpu.max", fmt.Sprintf("%d %d", containerCPUQuota, containerCPUQuota)),
+	)
+	// swap is not accounted on hosts without swap
+	if swapErr := writeCgroupFile(cgroup, "memory.swap.max", "0"); !errors.Is(swapErr, os.ErrNotExist) {
+		err = errors.Join(err, swapErr)
+	}
+	if cpus != "" {
+		err = errors.Join(err, writeCgroupFile(cgroup, "cpuset.cpus", cpus))
+	}
+	if err != nil {
+		removeCgroup(cgroup)
+		return fmt.Errorf("failed to limit cgroup: %w", err)
+	}
+	return nil
+}
+
+func removeCgroup(cgroup string) {
+	logger := logrus.WithField("cgroup", cgroup)
+	if err := writeCgroupFile(cgroup, "cgroup.kill", "1"); err != nil {
+		logger.WithError(err).Warn("Failed to kill cgroup")
+	}
+	deadline := time.Now().Add(cgroupRemoveWait)
+	for {
+		err := os.Remove(cgroup)
+		if err == nil || !errors.Is(err, syscall.EBUSY) || time.Now().After(deadline) {
+			if err != nil {
+				logger.WithError(err).Warn("Failed to remove cgroup")
+			}
+			return
+		}
+		time.Sleep(execPollInterval)
+	}
+}
+
+func oomKilled(cgroup string) (bool, error) {
+	file, err := os.Open(filepath.Join(cgroup, "memory.events"))
+	if err != nil {
+		return false, fmt.Errorf("failed to read memory events: %w", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if count, ok := strings.CutPrefix(scanner.Text(), "oom_kill "); ok {
+			return count != "0", nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to read memory events: %w", err)
+	}
+	return false, nil
+}
+
+func writeCgroupFile(cgroup, name, value string) error {
+	return os.WriteFile(filepath.Join(cgroup, name), []byte(value), 0o644)
+}
+
+func closeFiles(files ...*os.File) {
+	for _, file := range files {
+		if file != nil {
+			_ = file.Close()
+		}
+	}
+}
+
+// splitArguments splits the input of a test into arguments the way xargs does.
+func splitArguments(input string) []string {
+	var (
+		args    []string
+		arg     strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, c := range input {
+		switch {
+		case escaped:
+			arg.WriteRune(c)
+			escaped = false
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(c)
+		case c == '\\':
+			escaped, inArg = true, true
+		case c == '\'' || c == '"':
+			quote, inArg = c, true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
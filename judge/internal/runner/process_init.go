@@ -0,0 +1,277 @@
+//go:build linux
+
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// sandboxInitArg is the argv[0] the judge binary is started with to run a sandbox, see Init.
+	sandboxInitArg  = "code-judgement-sandbox"
+	sandboxSpecEnv  = "CODE_JUDGEMENT_SANDBOX"
+	sandboxReportFd = 3   // the first of the extra files
+	commandNotFound = 127 // as in a shell
+)
+
+var sandboxTmpfsDirs = []string{"/tmp", "/dev/shm"}
+
+var sandboxDevices = []string{"/dev/null", "/dev/zero", "/dev/full", "/dev/random", "/dev/urandom"}
+
+type sandboxSpec struct {
+	Root      string         `json:"root"`      // an empty directory the root of the sandbox is built on
+	ReadOnly  []string       `json:"read_only"` // paths of the host mounted read-only at the same paths
+	Mounts    []sandboxMount `json:"mounts"`    // the only directories the program can write to, besides sandboxTmpfsDirs
+	Dir       string         `json:"dir"`
+	Command   []string       `json:"command"`
+	Env       []string       `json:"env"`
+	UID       int            `json:"uid"`
+	GID       int            `json:"gid"`
+	Phase     string         `json:"phase"`
+	TimeLimit time.Duration  `json:"time_limit"`
+}
+
+type sandboxMount struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Writable bool   `json:"writable"`
+}
+
+type sandboxReport struct {
+	execution
+	Error string `json:"error,omitempty"`
+}
+
+// Init runs the sandbox of the process runner if the binary was started as one, first thing in main.
+func Init() {
+	if len(os.Args) == 0 || os.Args[0] != sandboxInitArg {
+		return
+	}
+	report := os.NewFile(sandboxReportFd, "report")
+	result, err := sandboxInit()
+	if err != nil {
+		result = &sandboxReport{Error: err.Error()}
+	}
+	if err := json.NewEncoder(report).Encode(result); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// sandboxInit is the first process of the namespaces of a sandbox, which end with it.
+func sandboxInit() (*sandboxReport, error) {
+	// no_new_privs is set on this thread, which must be the one starting the program to pass it on
+	runtime.LockOSThread()
+
+	var spec sandboxSpec
+	if err := json.Unmarshal([]byte(os.Getenv(sandboxSpecEnv)), &spec); err != nil {
+		return nil, fmt.Errorf("invalid sandbox spec: %w", err)
+	}
+	if len(spec.Command) == 0 {
+		return nil, errors.New("no command to run")
+	}
+	if err := mountSandbox(spec); err != nil {
+		return nil, err
+	}
+	if err := limitSandbox(spec); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(spec.Command[0], spec.Command[1:]...)
+	cmd.Dir = spec.Dir
+	cmd.Env = spec.Env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: uint32(spec.UID), Gid: uint32(spec.GID), Groups: []uint32{}},
+	}
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return &sandboxReport{execution: execution{Phase: spec.Phase, Status: commandNotFound}}, nil
+	}
+	os.Stdin.Close()
+	os.Stdout.Close()
+
+	var timedOut atomic.Bool
+	timer := time.AfterFunc(spec.TimeLimit, func() {
+		timedOut.Store(true)
+		_ = unix.Kill(-1, unix.SIGKILL)
+	})
+	defer timer.Stop()
+
+	// orphans of the program are reparented to this process
+	var status unix.WaitStatus
+	var usage unix.Rusage
+	for {
+		pid, err := unix.Wait4(-1, &status, 0, &usage)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to wait for program: %w", err)
+		}
+		if pid == cmd.Process.Pid {
+			break
+		}
+	}
+	wallTime := time.Since(start)
+	_ = unix.Kill(-1, unix.SIGKILL)
+
+	result := &sandboxReport{execution: execution{
+		Phase:      spec.Phase,
+		Status:     int64(status.ExitStatus()),
+		WallTime:   wallTime.Seconds(),
+		UserTime:   time.Duration(usage.Utime.Nano()).Seconds(),
+		SystemTime: time.Duration(usage.Stime.Nano()).Seconds(),
+		Memory:     usage.Maxrss, // kilobytes
+	}}
+	if status.Signaled() {
+		result.Status = signalExitBase + int64(status.Signal())
+		result.TimedOut = timedOut.Load() || status.Signal() == unix.SIGXCPU
+	}
+	return result, nil
+}
+
+// mountSandbox replaces the root of the host by a tmpfs holding the paths of the spec.
+func mountSandbox(spec sandboxSpec) error {
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %w", err)
+	}
+	if err := unix.Mount("tmpfs", spec.Root, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "size=1m,mode=0755"); err != nil {
+		return fmt.Errorf("failed to mount root: %w", err)
+	}
+	readOnly := uint64(unix.MOUNT_ATTR_RDONLY | unix.MOUNT_ATTR_NOSUID | unix.MOUNT_ATTR_NODEV)
+	for _, path := range spec.ReadOnly {
+		if err := bindPath(spec.Root, path, path, readOnly); err != nil {
+			return err
+		}
+	}
+	for _, mount := range spec.Mounts {
+		attr := readOnly
+		if mount.Writable {
+			attr &^= unix.MOUNT_ATTR_RDONLY
+		}
+		if err := bindPath(spec.Root, mount.Source, mount.Target, attr); err != nil {
+			return err
+		}
+	}
+	for _, device := range sandboxDevices {
+		if err := bindPath(spec.Root, device, device, unix.MOUNT_ATTR_NOSUID|unix.MOUNT_ATTR_NOEXEC); err != nil {
+			return err
+		}
+	}
+	for name, target := range map[string]string{"fd": "/proc/self/fd", "stdin": "/proc/self/fd/0",
+		"stdout": "/proc/self/fd/1", "stderr": "/proc/self/fd/2"} {
+		if err := os.Symlink(target, filepath.Join(spec.Root, "dev", name)); err != nil {
+			return fmt.Errorf("failed to link /dev/%s: %w", name, err)
+		}
+	}
+	for _, dir := range sandboxTmpfsDirs {
+		target := filepath.Join(spec.Root, dir)
+		if err := os.MkdirAll(target, 0o755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+		if err := unix.Mount("tmpfs", target, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "size=64m,mode=1777"); err != nil {
+			return fmt.Errorf("failed to mount %s: %w", dir, err)
+		}
+	}
+	proc := filepath.Join(spec.Root, "proc")
+	if err := os.Mkdir(proc, 0o555); err != nil {
+		return fmt.Errorf("failed to create /proc: %w", err)
+	}
+	if err := unix.Mount("proc", proc, "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("failed to mount /proc: %w", err)
+	}
+
+	if err := unix.Chdir(spec.Root); err != nil {
+		return fmt.Errorf("failed to enter root: %w", err)
+	}
+	if err := unix.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("failed to pivot root: %w", err)
+	}
+	if err := unix.Unmount(".", unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("failed to unmount root of the host: %w", err)
+	}
+	if err := unix.Chdir("/"); err != nil {
+		return fmt.Errorf("failed to enter root: %w", err)
+	}
+	if err := unix.MountSetattr(unix.AT_FDCWD, "/", 0, &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY}); err != nil {
+		return fmt.Errorf("failed to make root read-only: %w", err)
+	}
+	return nil
+}
+
+// bindPath copies symbolic links rather than mounting them, so that /bin -> usr/bin holds.
+func bindPath(root, source, target string, attr uint64) error {
+	info, err := os.Lstat(source)
+	if err != nil {
+		return fmt.Errorf("failed to mount %s: %w", source, err)
+	}
+	target = filepath.Join(root, target)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return fmt.Errorf("failed to mount %s: %w", source, err)
+	}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		link, err := os.Readlink(source)
+		if err == nil {
+			err = os.Symlink(link, target)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to link %s: %w", source, err)
+		}
+		return nil
+	case info.IsDir():
+		err = os.MkdirAll(target, 0o755)
+	default:
+		err = os.WriteFile(target, nil, 0o644)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to mount %s: %w", source, err)
+	}
+	if err := unix.Mount(source, target, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("failed to mount %s: %w", source, err)
+	}
+	if err := unix.MountSetattr(unix.AT_FDCWD, target, unix.AT_RECURSIVE, &unix.MountAttr{Attr_set: attr}); err != nil {
+		return fmt.Errorf("failed to mount %s: %w", source, err)
+	}
+	return nil
+}
+
+// limitSandbox drops privileges and sets the rlimits of the program.
+func limitSandbox(spec sandboxSpec) error {
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to set no_new_privs: %w", err)
+	}
+	for capability := 0; capability <= unix.CAP_LAST_CAP; capability++ {
+		err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(capability), 0, 0, 0)
+		if err != nil && !errors.Is(err, unix.EINVAL) {
+			return fmt.Errorf("failed to drop capability %d: %w", capability, err)
+		}
+	}
+	cpuTime := uint64(spec.TimeLimit.Seconds()) + 1
+	limits := map[int]unix.Rlimit{
+		unix.RLIMIT_FSIZE: {Cur: fileSizeLimit, Max: fileSizeLimit},
+		unix.RLIMIT_CORE:  {Cur: 0, Max: 0},
+		unix.RLIMIT_CPU:   {Cur: cpuTime, Max: cpuTime + 1},
+	}
+	for resource, limit := range limits {
+		if err := unix.Setrlimit(resource, &limit); err != nil {
+			return fmt.Errorf("failed to set rlimit %d: %w", resource, err)
+		}
+	}
+	return nil
+}
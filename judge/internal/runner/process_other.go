@@ -0,0 +1,29 @@
+//go:build !linux
+
+package runner
+
+import (
+	"context"
+	"errors"
+
+	"github.com/CT1403-2/Code-Judgement/judge/config"
+	"github.com/CT1403-2/Code-Judgement/proto"
+)
+
+// processRunner needs the namespaces and cgroups of Linux, it fails every run elsewhere.
+type processRunner struct{}
+
+func newProcessRunner(*config.Config) Runner {
+	return processRunner{}
+}
+
+func (processRunner) Run(context.Context, *proto.Question, *proto.Submission) (*Result, error) {
+	return nil, errors.New("the process runner only runs on linux")
+}
+
+func (processRunner) Close() error {
+	return nil
+}
+
+// Init does nothing, the process runner has no sandbox to run outside of Linux.
+func Init() {}
@@ -0,0 +1,366 @@
+//go:build linux
+
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CT1403-2/Code-Judgement/judge/config"
+	"github.com/CT1403-2/Code-Judgement/proto"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// TestMain lets the test binary run the sandboxes of the process runner, as the judge binary does.
+func TestMain(m *testing.M) {
+	Init()
+	os.Exit(m.Run())
+}
+
+type ProcessRunnerSuite struct {
+	suite.Suite
+	config *config.Config
+}
+
+// TestProcessRunnerSuite runs submissions in the sandbox of the process runner. It needs root and cgroup v2
+// with the memory controller and is skipped otherwise, run it with make test-process.
+func TestProcessRunnerSuite(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(ProcessRunnerSuite))
+}
+
+func (s *ProcessRunnerSuite) SetupSuite() {
+	if os.Geteuid() != 0 {
+		s.T().Skip("the process runner runs as root")
+	}
+	controllers, err := os.ReadFile("/sys/fs/cgroup/cgroup.controllers")
+	if err != nil || !strings.Contains(string(controllers), "memory") {
+		s.T().Skip("the process runner needs cgroup v2 with the memory controller")
+	}
+
+	s.config = &config.Config{
+		Runner: config.RunnerConfig{
+			Type:      config.ProcessRunner,
+			Languages: config.DefaultLanguages,
+			Process: config.ProcessConfig{
+				WorkDir:       "/var/lib/judge/runs",
+				Cgroup:        "/sys/fs/cgroup/judge-test",
+				FirstUID:      200000,
+				UIDs:          8,
+				ReadOnlyPaths: config.DefaultReadOnlyPaths,
+			},
+		},
+	}
+}
+
+// run judges the code of codeFile in language, failing the test if the runner fails.
+func (s *ProcessRunnerSuite) run(question *proto.Question, language, codeFile string) *Result {
+	code, err := os.ReadFile(codeFile)
+	if err != nil {
+		s.Failf("Failed to read test code file: %v", err.Error())
+	}
+
+	submission := &proto.Submission{
+		Id:         stringPtr(filepath.Base(codeFile)),
+		QuestionId: question.GetId(),
+		Code:       code,
+		Language:   language,
+		State:      statePtr(proto.SubmissionState_SUBMISSION_STATE_JUDGING),
+	}
+
+	runner := New(s.config)
+
+	ctx := context.Background()
+	result, err := runner.Run(ctx, question, submission)
+
+	if err != nil {
+		s.Failf("Error running submission: %v", err.Error())
+	}
+	return result
+}
+
+func (s *ProcessRunnerSuite) TestLanguages() {
+	question := &proto.Question{
+		Id:        stringPtr("q127"),
+		Title:     "Sum",
+		Statement: "Print the sum of n numbers.",
+		TestCases: []*proto.TestCase{
+			{Input: "3\n1 2 3\n", Output: "6\n"},
+			{Input: "1\n-5\n", Output: "-5\n"},
+		},
+		Limitations: &proto.Limitations{
+			Duration: 2000,
+			Memory:   512,
+		},
+	}
+
+	testCases := []struct {
+		language string
+		codeFile string
+		expected proto.SubmissionState
+	}{
+		{"c", "test_data/sum_c_code", proto.SubmissionState_SUBMISSION_STATE_OK},
+		{"cpp", "test_data/sum_cpp_code", proto.SubmissionState_SUBMISSION_STATE_OK},
+		{"python", "test_data/sum_python_code", proto.SubmissionState_SUBMISSION_STATE_OK},
+		{"cpp", "test_data/non_compilable_cpp_code", proto.SubmissionState_SUBMISSION_STATE_COMPILE_ERROR},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.codeFile, func() {
+			result := s.run(question, tc.language, tc.codeFile)
+			s.Equal(tc.expected, result.State)
+		})
+	}
+}
+
+func (s *ProcessRunnerSuite) TestLimits() {
+	question := &proto.Question{
+		Id:        stringPtr("q136"),
+		Title:     "Limits",
+		TestCases: []*proto.TestCase{{Input: "", Output: ""}},
+		Limitations: &proto.Limitations{
+			Duration: 1000,
+			Memory:   64,
+		},
+	}
+
+	testCases := []struct {
+		language string
+		codeFile string
+		expected proto.SubmissionState
+		signal   int32
+	}{
+		{"go", "test_data/time_limit_code", proto.SubmissionState_SUBMISSION_STATE_TIME_LIMIT_EXCEEDED, 0},
+		{"c", "test_data/memory_limit_c_code", proto.SubmissionState_SUBMISSION_STATE_MEMORY_LIMIT_EXCEEDED, 0},
+		{"c", "test_data/segfault_c_code", proto.SubmissionState_SUBMISSION_STATE_RUNTIME_ERROR, 11},
+		{"c", "test_data/abort_c_code", proto.SubmissionState_SUBMISSION_STATE_RUNTIME_ERROR, 6},
+		{"c", "test_data/stdout_flood_c_code", proto.SubmissionState_SUBMISSION_STATE_OUTPUT_LIMIT_EXCEEDED, 0},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.codeFile, func() {
+			result := s.run(question, tc.language, tc.codeFile)
+			s.Equal(tc.expected, result.State)
+			s.Equal(tc.signal, result.Signal)
+		})
+	}
+}
+
+// TestNoMemoryLimit runs a question without a memory limit, which the docker runner leaves unlimited too.
+func (s *ProcessRunnerSuite) TestNoMemoryLimit() {
+	question := &proto.Question{
+		Id:          stringPtr("q137"),
+		Title:       "Sum",
+		TestCases:   []*proto.TestCase{{Input: "2\n1 2\n", Output: "3\n"}},
+		Limitations: &proto.Limitations{Duration: 2000},
+	}
+
+	result := s.run(question, "python", "test_data/sum_python_code")
+	s.Equal(proto.SubmissionState_SUBMISSION_STATE_OK, result.State)
+}
+
+// TestSandbox runs the programs of the docker runner's sandbox test that the process sandbox also stops.
+func (s *ProcessRunnerSuite) TestSandbox() {
+	question := &proto.Question{
+		Id:        stringPtr("q134"),
+		Title:     "Sandbox",
+		TestCases: []*proto.TestCase{{Input: "", Output: "restricted\n"}},
+		Limitations: &proto.Limitations{
+			Duration: 2000,
+			Memory:   512,
+		},
+	}
+
+	testCases := []struct {
+		language string
+		codeFile string
+	}{
+		{"python", "test_data/readonly_rootfs_python_code"},
+		{"c", "test_data/fork_bomb_c_code"},
+		{"python", "test_data/capabilities_python_code"},
+		{"python", "test_data/kill_python_code"},
+		{"python", "test_data/no_new_privileges_python_code"},
+		{"python", "test_data/root_user_python_code"},
+		{"python", "test_data/suite_reader_python_code"},
+		{"python", "test_data/host_files_python_code"},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.codeFile, func() {
+			result := s.run(question, tc.language, tc.codeFile)
+			s.Equal(proto.SubmissionState_SUBMISSION_STATE_OK, result.State)
+		})
+	}
+}
+
+func (s *ProcessRunnerSuite) TestInteractive() {
+	interactor, err := os.ReadFile("test_data/guess_interactor")
+	if err != nil {
+		s.Failf("Failed to read interactor file: %v", err.Error())
+	}
+
+	question := &proto.Question{
+		Id:                 stringPtr("q131"),
+		Title:              "Guess",
+		TestCases:          []*proto.TestCase{{Input: "37"}, {Input: "100"}},
+		Interactor:         interactor,
+		InteractorLanguage: "python",
+		Limitations: &proto.Limitations{
+			Duration: 1000,
+			Memory:   512,
+		},
+	}
+
+	testCases := []struct {
+		codeFile string
+		expected proto.SubmissionState
+	}{
+		{"test_data/guess_code", proto.SubmissionState_SUBMISSION_STATE_OK},
+		{"test_data/guess_wrong_code", proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER},
+		{"test_data/guess_idle_code", proto.SubmissionState_SUBMISSION_STATE_IDLENESS_LIMIT_EXCEEDED},
+		{"test_data/guess_peek_code", proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.codeFile, func() {
+			result := s.run(question, "python", tc.codeFile)
+			s.Equal(tc.expected, result.State)
+		})
+	}
+}
+
+func (s *ProcessRunnerSuite) TestChecker() {
+	checker, err := os.ReadFile("test_data/permutation_checker")
+	if err != nil {
+		s.Failf("Failed to read checker file: %v", err.Error())
+	}
+
+	question := &proto.Question{
+		Id:              stringPtr("q128"),
+		Title:           "Permutation",
+		TestCases:       []*proto.TestCase{{Input: "3 1 2", Output: "1 2 3"}},
+		InputMode:       proto.InputMode_INPUT_MODE_STDIN,
+		Checker:         checker,
+		CheckerLanguage: "python",
+		Limitations: &proto.Limitations{
+			Duration: 1000,
+			Memory:   512,
+		},
+	}
+
+	result := s.run(question, "python", "test_data/echo_python_code")
+	s.Equal(proto.SubmissionState_SUBMISSION_STATE_OK, result.State)
+}
+
+// TestCreateCgroup checks the limits written for a program, in a plain directory standing in for the cgroup.
+func TestCreateCgroup(t *testing.T) {
+	testCases := []struct {
+		name     string
+		memory   int64
+		cpus     string
+		expected map[string]string
+	}{
+		{"limited", 256, "", map[string]string{
+			"memory.max": "268435456", "memory.swap.max": "0", "pids.max": "256", "cpu.max": "100000 100000",
+		}},
+		{"no memory limit", 0, "", map[string]string{"memory.max": "max"}},
+		{"pinned", 64, "2-3", map[string]string{"memory.max": "67108864", "cpuset.cpus": "2-3"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cgroup := filepath.Join(t.TempDir(), "cgroup")
+			require.NoError(t, createCgroup(cgroup, tc.memory, tc.cpus))
+			for name, value := range tc.expected {
+				content, err := os.ReadFile(filepath.Join(cgroup, name))
+				require.NoError(t, err)
+				require.Equal(t, value, string(content), name)
+			}
+			if tc.cpus == "" {
+				require.NoFileExists(t, filepath.Join(cgroup, "cpuset.cpus"))
+			}
+		})
+	}
+}
+
+func TestUIDPool(t *testing.T) {
+	pool := newUIDPool(1000, 5)
+
+	first, err := pool.acquire()
+	require.NoError(t, err)
+	require.Equal(t, 1000, first)
+	second, err := pool.acquire()
+	require.NoError(t, err)
+	require.Equal(t, 1002, second)
+	_, err = pool.acquire()
+	require.Error(t, err, "five ids hold two runs")
+
+	pool.release(first)
+	again, err := pool.acquire()
+	require.NoError(t, err)
+	require.Equal(t, first, again)
+}
+
+// TestDecodeReport decodes reports as Init encodes them.
+func TestDecodeReport(t *testing.T) {
+	encode := func(report sandboxReport) []byte {
+		content, err := json.Marshal(report)
+		require.NoError(t, err)
+		return content
+	}
+	program := execution{Phase: "run", Status: 1, WallTime: 0.5, UserTime: 0.25, Memory: 2048}
+
+	decoded, err := decodeReport("0", encode(sandboxReport{execution: program}), nil)
+	require.NoError(t, err)
+	require.Equal(t, program, *decoded)
+
+	_, err = decodeReport("0", encode(sandboxReport{Error: "failed to pivot root"}), nil)
+	require.ErrorContains(t, err, "failed to pivot root")
+
+	_, err = decodeReport("0", nil, errors.New("signal: killed"))
+	require.ErrorContains(t, err, "ended without a report: signal: killed")
+}
+
+func TestOOMKilled(t *testing.T) {
+	testCases := []struct {
+		events   string
+		expected bool
+	}{
+		{"low 0\nhigh 0\nmax 3\noom 1\noom_kill 0\n", false},
+		{"low 0\nhigh 0\nmax 9\noom 2\noom_kill 1\noom_group_kill 0\n", true},
+	}
+
+	for _, tc := range testCases {
+		cgroup := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(cgroup, "memory.events"), []byte(tc.events), 0o644))
+		killed, err := oomKilled(cgroup)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, killed)
+	}
+}
+
+func TestSplitArguments(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{"", nil},
+		{"a b\tc\nd", []string{"a", "b", "c", "d"}},
+		{"  padded  ", []string{"padded"}},
+		{`"two words" 'single "quoted"'`, []string{"two words", `single "quoted"`}},
+		{`back\ slash \'`, []string{"back slash", "'"}},
+		{`""`, []string{""}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			require.Equal(t, tc.expected, splitArguments(tc.input))
+		})
+	}
+}
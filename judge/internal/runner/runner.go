@@ -0,0 +1,162 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/CT1403-2/Code-Judgement/judge/config"
+	"github.com/CT1403-2/Code-Judgement/proto"
+	"github.com/sirupsen/logrus"
+)
+
+// suiteRunner runs a script over a suite the way run.sh and check.sh do, wherever the runner runs programs.
+// It also reports whether the memory limit of the whole run was hit.
+type suiteRunner interface {
+	runSuite(ctx context.Context, logger *logrus.Entry, script string, limitations *proto.Limitations,
+		language config.LanguageConfig, interactor *config.LanguageConfig, suite SuiteConfig) (*suiteOutput, bool, error)
+}
+
+// runSubmission judges a submission with the suites of runner, checking the outputs with the checker
+// of the question when it has one.
+func runSubmission(ctx context.Context, cfg *config.Config, runner suiteRunner, question *proto.Question,
+	submission *proto.Submission) (*Result, error) {
+	logger := logrus.WithFields(logrus.Fields{"submission_id": *submission.Id})
+	logger.Info("Starting submission evaluation")
+
+	language, err := cfg.Runner.Language(submission.GetLanguage())
+	if err != nil {
+		return nil, err
+	}
+
+	tests := testCases(question)
+	suite := SuiteConfig{
+		Code:      submission.Code,
+		Arguments: question.GetInputMode() == proto.InputMode_INPUT_MODE_ARGUMENTS,
+		Tests:     make([]SuiteTest, len(tests)),
+	}
+	for i, test := range tests {
+		suite.Tests[i] = SuiteTest{Input: test.GetInput()}
+	}
+
+	var interactor *config.LanguageConfig
+	if isInteractive(question) {
+		interactorLanguage, err := cfg.Runner.Language(question.GetInteractorLanguage())
+		if err != nil {
+			return nil, fmt.Errorf("invalid interactor: %w", err)
+		}
+		interactor = &interactorLanguage
+		suite.Interactor = question.GetInteractor()
+	}
+
+	outputs, isOOMKilled, err := runner.runSuite(ctx, logger, runScript, question.Limitations,
+		language, interactor, suite)
+	if err != nil {
+		return nil, err
+	}
+
+	check := comparator(question)
+	if len(question.GetChecker()) > 0 && !isInteractive(question) {
+		check, err = runChecker(ctx, cfg, runner, logger, question, tests, outputs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := evaluateSuite(question, tests, outputs, isOOMKilled, check)
+	if err != nil {
+		return nil, err
+	}
+	logger.WithFields(logrus.Fields{
+		"result":       result.State.String(),
+		"failed_test":  result.FailedTest,
+		"exit_code":    result.ExitCode,
+		"signal":       result.Signal,
+		"wall_time":    result.WallTime,
+		"cpu_time":     result.CPUTime,
+		"memory":       result.Memory,
+		"compile_time": result.CompileTime,
+		"score":        result.Score,
+	}).Info("Submission evaluated")
+
+	return result, nil
+}
+
+// evaluateSuite judges every test, so that the score can count passed tests after a failure.
+// The verdict is the one of the first failing test.
+func evaluateSuite(question *proto.Question, tests []*proto.TestCase, outputs *suiteOutput,
+	isOOMKilled bool, check answerChecker) (*Result, error) {
+	result := &Result{MaxScore: maxScore(question)}
+	if outputs.compile != nil {
+		result.CompileTime = milliseconds(outputs.compile.WallTime)
+	}
+	// the compile phase has limits of its own, running out of them is not the fault of the program
+	if outputs.compileFailed() {
+		result.State = proto.SubmissionState_SUBMISSION_STATE_COMPILE_ERROR
+		result.CompilerOutput = outputs.compilerOutput(isOOMKilled)
+		return result, nil
+	}
+	if outputs.interactorCompileFailed() {
+		result.State = proto.SubmissionState_SUBMISSION_STATE_FAILED
+		return result, nil
+	}
+
+	states := make([]proto.SubmissionState, len(tests))
+	// credits are the fractions of the points of the tests earned, only checkers give partial ones
+	credits := make([]float64, len(tests))
+	for i, test := range tests {
+		output, ok := outputs.tests[i]
+		if !ok {
+			if !isOOMKilled {
+				return nil, fmt.Errorf("missing output of test %d", i+1)
+			}
+			states[i] = proto.SubmissionState_SUBMISSION_STATE_MEMORY_LIMIT_EXCEEDED
+		} else {
+			result.addUsage(output.execution)
+			oomKilled := isOOMKilled || output.OOMKilled
+			if output.outputLimitExceeded() {
+				states[i] = proto.SubmissionState_SUBMISSION_STATE_OUTPUT_LIMIT_EXCEEDED
+			} else if isInteractive(question) {
+				states[i] = evaluateInteraction(output, oomKilled)
+			} else {
+				states[i] = *evaluateResult(output.execution, oomKilled, func() proto.SubmissionState {
+					var state proto.SubmissionState
+					state, credits[i] = check(i, test, output)
+					return state
+				})
+			}
+		}
+		if states[i] == proto.SubmissionState_SUBMISSION_STATE_OK {
+			credits[i] = 1
+		}
+
+		if states[i] != proto.SubmissionState_SUBMISSION_STATE_OK && result.FailedTest == 0 {
+			result.State = states[i]
+			result.FailedTest = int32(i + 1)
+			if states[i] == proto.SubmissionState_SUBMISSION_STATE_RUNTIME_ERROR {
+				result.ExitCode, result.Signal = exitStatus(output.Status)
+			}
+		}
+	}
+	if result.FailedTest == 0 {
+		result.State = proto.SubmissionState_SUBMISSION_STATE_OK
+	}
+	result.Score = score(question, tests, states, credits)
+	return result, nil
+}
+
+// evaluateResult judges a single test run, deferring to checkAnswer when the program exited cleanly.
+func evaluateResult(result execution, isOOMKilled bool, checkAnswer func() proto.SubmissionState) *proto.SubmissionState {
+	if isOOMKilled && result.Status != 0 {
+		return statePtr(proto.SubmissionState_SUBMISSION_STATE_MEMORY_LIMIT_EXCEEDED)
+	}
+
+	if result.Status == 0 {
+		return statePtr(checkAnswer())
+	}
+
+	if result.TimedOut {
+		return statePtr(proto.SubmissionState_SUBMISSION_STATE_TIME_LIMIT_EXCEEDED)
+	}
+
+	return statePtr(proto.SubmissionState_SUBMISSION_STATE_RUNTIME_ERROR)
+}
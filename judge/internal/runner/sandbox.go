@@ -0,0 +1,125 @@
+package runner
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+const (
+	sandboxUser      = "root" // see common.sh
+	pidsLimit        = 256
+	fileSizeLimit    = 64 * 1024 * 1024 // bytes, including the stdout of a test
+	maxContainerLogs = 64 * 1024        // bytes
+)
+
+// sandboxTmpfs are the only writable directories of a container.
+var sandboxTmpfs = map[string]string{
+	"/playground/judge":      "rw,nosuid,nodev,size=512m,mode=0700",
+	"/playground/app":        "rw,nosuid,nodev,size=512m,uid=1000,gid=1000,mode=0700",
+	"/playground/interactor": "rw,nosuid,nodev,size=64m,uid=1001,gid=1001,mode=0700",
+	"/home/runner":           "rw,nosuid,nodev,size=512m,uid=1000,gid=1000,mode=0700",
+	"/tmp":                   "rw,nosuid,nodev,size=64m,mode=1777",
+}
+
+// sandboxCapabilities are those of the scripts, the program and the interactor are started without any.
+var sandboxCapabilities = []string{
+	"CHOWN",        // hands the code, the tests and the input of the interactor to their users
+	"DAC_OVERRIDE", // reads the results and cleans the directories of the users, private to each
+	"FOWNER",       // cleans the files a run leaves in sticky directories of its own
+	"KILL",         // kills what a run leaves running, in reset.sh
+	"SETUID",       // starts the program and the interactor as their users, with setpriv
+	"SETGID",       // and their groups
+	"SETPCAP",      // empties the bounding set of the program and the interactor, with setpriv
+}
+
+// seccompDefault is profiles/seccomp/default.json of github.com/docker/docker v28.1.1, copied as is.
+//
+//go:embed seccomp_default.json
+var seccompDefault []byte
+
+// seccompDenied would let a program read or steer the other processes of its user.
+var seccompDenied = []string{"ptrace", "process_vm_readv", "process_vm_writev"}
+
+// seccompProfile returns seccompDefault without seccompDenied.
+func seccompProfile() (string, error) {
+	var profile map[string]json.RawMessage
+	var rules []map[string]json.RawMessage
+	if err := json.Unmarshal(seccompDefault, &profile); err != nil {
+		return "", fmt.Errorf("invalid seccomp profile: %w", err)
+	}
+	if err := json.Unmarshal(profile["syscalls"], &rules); err != nil {
+		return "", fmt.Errorf("invalid seccomp profile: %w", err)
+	}
+	kept := rules[:0]
+	for _, rule := range rules {
+		var names []string
+		if err := json.Unmarshal(rule["names"], &names); err != nil {
+			return "", fmt.Errorf("invalid seccomp profile: %w", err)
+		}
+		names = slices.DeleteFunc(names, func(name string) bool {
+			return slices.Contains(seccompDenied, name)
+		})
+		if len(names) == 0 {
+			continue
+		}
+		rule["names"], _ = json.Marshal(names)
+		kept = append(kept, rule)
+	}
+	profile["syscalls"], _ = json.Marshal(kept)
+	content, err := json.Marshal(profile)
+	if err != nil {
+		return "", fmt.Errorf("invalid seccomp profile: %w", err)
+	}
+	return string(content), nil
+}
+
+// sandboxHostConfig returns the host config of a container, whose memory limit is set per run.
+func sandboxHostConfig(cpus string) (*container.HostConfig, error) {
+	profile, err := seccompProfile()
+	if err != nil {
+		return nil, err
+	}
+	return &container.HostConfig{
+		ReadonlyRootfs: true,
+		Tmpfs:          sandboxTmpfs,
+		CapDrop:        []string{"ALL"},
+		CapAdd:         sandboxCapabilities,
+		SecurityOpt:    []string{"no-new-privileges", "seccomp=" + profile},
+		Resources: container.Resources{
+			MemorySwappiness: &[]int64{0}[0],
+			CPUPeriod:        containerCPUQuota,
+			CPUQuota:         containerCPUQuota,
+			CpusetCpus:       cpus,
+			PidsLimit:        &[]int64{pidsLimit}[0],
+			Ulimits: []*container.Ulimit{
+				{Name: "fsize", Soft: fileSizeLimit, Hard: fileSizeLimit},
+			},
+		},
+	}, nil
+}
+
+// limitedBuffer keeps the first limit bytes written to it and drops the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit   int
+	dropped bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	room := max(b.limit-b.Len(), 0)
+	b.Buffer.Write(p[:min(len(p), room)])
+	b.dropped = b.dropped || len(p) > room
+	return len(p), nil
+}
+
+func (b *limitedBuffer) text() string {
+	if b.dropped {
+		return truncate(b.String(), b.limit-1)
+	}
+	return truncate(b.String(), b.limit)
+}
@@ -0,0 +1,38 @@
+package runner
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeccompProfile(t *testing.T) {
+	profile, err := seccompProfile()
+	require.NoError(t, err)
+
+	var parsed struct {
+		DefaultAction string `json:"defaultAction"`
+		Syscalls      []struct {
+			Names  []string `json:"names"`
+			Action string   `json:"action"`
+		} `json:"syscalls"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(profile), &parsed))
+	require.Equal(t, "SCMP_ACT_ERRNO", parsed.DefaultAction)
+
+	allowed := map[string]bool{}
+	for _, rule := range parsed.Syscalls {
+		require.NotEmpty(t, rule.Names)
+		for _, name := range rule.Names {
+			allowed[name] = true
+		}
+	}
+	for _, name := range seccompDenied {
+		require.False(t, allowed[name], name)
+	}
+	// the rest of the default profile of Docker is kept
+	for _, name := range []string{"read", "write", "clone", "execve", "wait4"} {
+		require.True(t, allowed[name], name)
+	}
+}
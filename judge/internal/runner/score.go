@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"math"
+
+	"github.com/CT1403-2/Code-Judgement/proto"
+)
+
+// defaultMaxScore is awarded for questions without subtasks when every test passes.
+const defaultMaxScore = 100
+
+func maxScore(question *proto.Question) int32 {
+	if len(question.GetSubtasks()) == 0 {
+		return defaultMaxScore
+	}
+	var total int32
+	for _, subtask := range question.GetSubtasks() {
+		total += subtask.GetPoints()
+	}
+	return total
+}
+
+// score sums the points of the question's subtasks given the verdict of each test and the fraction of
+// its points it earned, which per test subtasks award even for tests that were not accepted.
+// Tests outside subtasks award no points, and a subtask without tests awards none either.
+func score(question *proto.Question, tests []*proto.TestCase, states []proto.SubmissionState,
+	credits []float64) int32 {
+	if len(question.GetSubtasks()) == 0 {
+		for _, state := range states {
+			if state != proto.SubmissionState_SUBMISSION_STATE_OK {
+				return 0
+			}
+		}
+		return defaultMaxScore
+	}
+
+	var total int32
+	for _, subtask := range question.GetSubtasks() {
+		var count, passed int32
+		var earned float64
+		for i, test := range tests {
+			if test.SubtaskId == nil || test.GetSubtaskId() != subtask.GetId() {
+				continue
+			}
+			count++
+			earned += credits[i]
+			if states[i] == proto.SubmissionState_SUBMISSION_STATE_OK {
+				passed++
+			}
+		}
+		if count == 0 {
+			continue
+		}
+
+		switch subtask.GetScoringMode() {
+		case proto.ScoringMode_SCORING_MODE_PER_TEST:
+			total += int32(math.Floor(float64(subtask.GetPoints()) * earned / float64(count)))
+		default:
+			if passed == count {
+				total += subtask.GetPoints()
+			}
+		}
+	}
+	return total
+}
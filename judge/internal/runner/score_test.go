@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/CT1403-2/Code-Judgement/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScore(t *testing.T) {
+	const (
+		ok = proto.SubmissionState_SUBMISSION_STATE_OK
+		wa = proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER
+	)
+	subtasks := []*proto.Subtask{
+		{Id: stringPtr("1"), Points: 30, ScoringMode: proto.ScoringMode_SCORING_MODE_ALL_OR_NOTHING},
+		{Id: stringPtr("2"), Points: 70, ScoringMode: proto.ScoringMode_SCORING_MODE_PER_TEST},
+	}
+	tests := []*proto.TestCase{
+		{}, // sample, outside subtasks
+		{SubtaskId: stringPtr("1")},
+		{SubtaskId: stringPtr("1")},
+		{SubtaskId: stringPtr("2")},
+		{SubtaskId: stringPtr("2")},
+	}
+
+	testCases := []struct {
+		name     string
+		question *proto.Question
+		tests    []*proto.TestCase
+		states   []proto.SubmissionState
+		credits  []float64 // nil when only accepted tests earn their points
+		score    int32
+		maxScore int32
+	}{
+		{"no subtasks accepted", &proto.Question{}, tests[:2], []proto.SubmissionState{ok, ok}, nil, 100, 100},
+		{"no subtasks rejected", &proto.Question{}, tests[:2], []proto.SubmissionState{ok, wa}, nil, 0, 100},
+		{"no subtasks partial", &proto.Question{}, tests[:2], []proto.SubmissionState{ok, wa}, []float64{1, 0.5}, 0, 100},
+		{"all subtasks", &proto.Question{Subtasks: subtasks}, tests, []proto.SubmissionState{ok, ok, ok, ok, ok}, nil, 100, 100},
+		{"sample does not count", &proto.Question{Subtasks: subtasks}, tests, []proto.SubmissionState{wa, ok, ok, ok, ok}, nil, 100, 100},
+		{"all or nothing", &proto.Question{Subtasks: subtasks}, tests, []proto.SubmissionState{ok, ok, wa, ok, ok}, nil, 70, 100},
+		{"all or nothing partial", &proto.Question{Subtasks: subtasks}, tests, []proto.SubmissionState{ok, ok, wa, ok, ok}, []float64{1, 1, 0.9, 1, 1}, 70, 100},
+		{"per test", &proto.Question{Subtasks: subtasks}, tests, []proto.SubmissionState{ok, ok, ok, wa, ok}, nil, 65, 100},
+		{"per test partial", &proto.Question{Subtasks: subtasks}, tests, []proto.SubmissionState{ok, ok, ok, wa, wa}, []float64{1, 1, 1, 0.5, 0.25}, 56, 100},
+		{"nothing", &proto.Question{Subtasks: subtasks}, tests, []proto.SubmissionState{ok, wa, ok, wa, wa}, nil, 0, 100},
+		{"empty subtask", &proto.Question{Subtasks: subtasks}, tests[:3], []proto.SubmissionState{ok, ok, ok}, nil, 30, 100},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			credits := tc.credits
+			if credits == nil {
+				credits = make([]float64, len(tc.states))
+				for i, state := range tc.states {
+					if state == ok {
+						credits[i] = 1
+					}
+				}
+			}
+			require.Equal(t, tc.score, score(tc.question, tc.tests, tc.states, credits))
+			require.Equal(t, tc.maxScore, maxScore(tc.question))
+		})
+	}
+}
@@ -4,15 +4,90 @@ import (
 	"context"
 	"github.com/CT1403-2/Code-Judgement/judge/config"
 	"github.com/CT1403-2/Code-Judgement/proto"
+	"github.com/sirupsen/logrus"
 )
 
 //go:generate mockery --name=Runner --filename=runner.go --outpkg=mocks
 type Runner interface {
-	Run(ctx context.Context, question *proto.Question, submission *proto.Submission) (*proto.SubmissionState, error)
+	Run(ctx context.Context, question *proto.Question, submission *proto.Submission) (*Result, error)
+	// Close removes the containers kept for later runs, if the runner keeps any.
+	Close() error
 }
 
+// Result is the verdict of a submission over all test cases of a question.
+type Result struct {
+	State proto.SubmissionState
+	// FailedTest is the 1-based index of the first failing test case, zero if all passed.
+	FailedTest int32
+	// ExitCode and Signal describe how the program of the failed test ended on a runtime error.
+	ExitCode int32
+	Signal   int32
+	// WallTime and CPUTime are in milliseconds and Memory is the peak resident set size in
+	// kilobytes, each the largest over the tests that ran.
+	WallTime int32
+	CPUTime  int32
+	Memory   int64
+	// CompileTime is the wall time of the compile phase in milliseconds, zero for languages without one.
+	CompileTime int32
+	// CompilerOutput holds the truncated compiler diagnostics of a compile error.
+	CompilerOutput string
+	Score          int32
+	MaxScore       int32
+}
+
+type cpusKey struct{}
+
+// WithCPUs pins the containers of the runs under ctx to a cpuset, e.g. "2" or "2-3".
+func WithCPUs(ctx context.Context, cpus string) context.Context {
+	return context.WithValue(ctx, cpusKey{}, cpus)
+}
+
+// cpusFromContext returns the cpuset set by WithCPUs, empty if the run is not pinned.
+func cpusFromContext(ctx context.Context) string {
+	cpus, _ := ctx.Value(cpusKey{}).(string)
+	return cpus
+}
+
+// New returns the runner of cfg.Runner.Type. The docker runner reuses up to cfg.Runner.PoolSize
+// containers per image and worker cpuset, started in the background.
 func New(cfg *config.Config) Runner {
+	if cfg.Runner.Type == config.ProcessRunner {
+		return newProcessRunner(cfg)
+	}
+	pool := newContainerPool(cfg.Runner.PoolSize, cfg.Name)
+	if cfg.Runner.PoolSize > 0 {
+		go func() {
+			if err := pool.warm(context.Background(), poolKeys(cfg.Runner)); err != nil {
+				logrus.WithError(err).Warn("Failed to warm container pool")
+			}
+		}()
+	}
 	return &dockerRunner{
 		config: cfg,
+		pool:   pool,
+	}
+}
+
+// poolKeys returns the keys the runs of a worker can take containers from.
+func poolKeys(cfg config.RunnerConfig) []poolKey {
+	cpus := cfg.CPUs
+	if len(cpus) == 0 {
+		cpus = []string{""}
+	}
+	var keys []poolKey
+	seen := make(map[poolKey]bool)
+	for _, id := range cfg.LanguageIds() {
+		language, err := cfg.Language(id)
+		if err != nil {
+			continue
+		}
+		for _, cpuset := range cpus {
+			key := poolKey{image: language.Image, cpus: cpuset}
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
 	}
+	return keys
 }
@@ -0,0 +1,12 @@
+#!/bin/bash
+cd /playground/app || exit
+# shellcheck source=common.sh
+. /playground/common.sh
+# the checker is no contestant program, it is handed the tests and the outputs it judges
+cp -r "$SUITE/tests" tests
+chown -R runner:runner tests
+count=$(jq '.tests' "$SUITE/suite.json")
+for ((i = 0; i < count; i++)); do
+  # shellcheck disable=SC2086
+  PHASE=check measure "$i" "$TIMEOUT" "${AS_PROGRAM[@]}" $RUN_COMMAND "tests/$i.input" "tests/$i.output" "tests/$i.answer" > "$RESULTS/$i.stdout" 2> "$RESULTS/$i.stderr"
+done
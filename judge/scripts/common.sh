@@ -0,0 +1,43 @@
+#!/bin/bash
+# The scripts run as root and start everything the suite runs as one of two unprivileged users: the
+# program as runner and the interactor as interactor. Neither can read the suite, the results or the
+# directory of the other, the interactor only talks to the program over the pipes it is handed.
+SUITE=/playground/judge/suite
+RESULTS=/playground/judge/results
+PROGRAM_HOME=/home/runner
+INTERACTOR_HOME=/playground/interactor/home
+
+# AS_PROGRAM and AS_INTERACTOR prefix a command to run it as the user of the program or of the
+# interactor, without capabilities, not even in its bounding set
+AS_PROGRAM=(setpriv --reuid=runner --regid=runner --clear-groups --inh-caps=-all --bounding-set=-all
+  env HOME="$PROGRAM_HOME")
+AS_INTERACTOR=(setpriv --reuid=interactor --regid=interactor --clear-groups --inh-caps=-all --bounding-set=-all
+  env HOME="$INTERACTOR_HOME" TMPDIR=/playground/interactor)
+
+# measure <name> <time limit> <command...> runs the command under the time limit and
+# writes its exit status and resource usage to $RESULTS/<name>.json
+measure() {
+  local name=$1 limit=$2
+  shift 2
+  /usr/bin/time -q -o "$RESULTS/$name.usage" \
+    -f '{"wall_time": %e, "user_time": %U, "system_time": %S, "memory": %M}' \
+    timeout "$limit" "$@"
+  local status=$?
+  jq -n --arg phase "$PHASE" --argjson status "$status" --argjson limit "$limit" \
+    --slurpfile usage "$RESULTS/$name.usage" \
+    '{phase: $phase, status: $status, timed_out: ($status == 124 and $usage[0].wall_time >= $limit)} + $usage[0]' \
+    > "$RESULTS/$name.json"
+  rm -f "$RESULTS/$name.usage"
+  return $status
+}
+
+# compile <name> <command> <time limit> <user...> runs the compile command of a language if it has one
+# as the user prefixed, failing if it does not succeed. The compiler diagnostics are kept in $RESULTS/<name>.output
+compile() {
+  local name=$1 command=$2 limit=$3
+  shift 3
+  if [ -z "$command" ]; then
+    return 0
+  fi
+  PHASE=compile measure "$name" "$limit" "$@" sh -c "$command" > "$RESULTS/$name.output" 2>&1
+}
@@ -0,0 +1,29 @@
+#!/bin/bash
+# compile.sh compiles the program of the suite, and the interactor if there is one, under the compile
+# limits of their languages. It exits with 1 if either does not compile, run.sh and check.sh run after it.
+cd /playground/app || exit
+# shellcheck source=common.sh
+. /playground/common.sh
+mkdir -p "$RESULTS"
+cp "$SUITE/code" "$SOURCE_FILE"
+chown -R runner:runner /playground/app
+status=0
+# shellcheck disable=SC2153
+if ! compile compile "$COMPILE_COMMAND" "$COMPILE_TIMEOUT" "${AS_PROGRAM[@]}"; then
+  status=1
+elif [ "$(jq -r '.interactive' "$SUITE/suite.json")" = "true" ]; then
+  # the interactor is built and run in a directory of its own the program can't open
+  cp go.mod /playground/interactor/
+  cp "$SUITE/interactor" "/playground/interactor/$INTERACTOR_SOURCE_FILE"
+  mkdir -p "$INTERACTOR_HOME"
+  chown -R interactor:interactor /playground/interactor
+  if ! (cd /playground/interactor && compile interactor-compile "$INTERACTOR_COMPILE_COMMAND" \
+    "$INTERACTOR_COMPILE_TIMEOUT" "${AS_INTERACTOR[@]}"); then
+    status=1
+  fi
+fi
+
+# caches the compilers left behind would count towards the memory of the tests
+find /tmp "$PROGRAM_HOME" -mindepth 1 -delete
+rm -rf "$INTERACTOR_HOME"
+exit $status
@@ -1,6 +1,40 @@
 #!/bin/bash
-jq -r '.code' /playground/app/suite.json | base64 -d > /playground/app/main.go
 cd /playground/app || exit
-go mod tidy
-timeout 60 go build -o main .
-jq -r '.input' /playground/app/suite.json | xargs timeout -v "$TIMEOUT" ./main
+# shellcheck source=common.sh
+. /playground/common.sh
+
+# the interactor was built in its own directory by compile.sh
+interactive=$(jq -r '.interactive' "$SUITE/suite.json")
+arguments=$(jq -r '.arguments' "$SUITE/suite.json")
+count=$(jq '.tests' "$SUITE/suite.json")
+for ((i = 0; i < count; i++)); do
+  if [ "$interactive" = "true" ]; then
+    # the pipes are opened here and only their ends are handed over, the program can't open them itself
+    rm -f /playground/judge/to_program /playground/judge/to_interactor /playground/interactor/log
+    mkfifo /playground/judge/to_program /playground/judge/to_interactor
+    install -o interactor -g interactor -m 0600 "$SUITE/tests/$i.input" /playground/interactor/input
+    # both sides open to_program first, opening the pipes in another order would block forever
+    # shellcheck disable=SC2086
+    (cd /playground/interactor && PHASE=interact measure "interactor-$i" "$INTERACTOR_TIMEOUT" \
+      "${AS_INTERACTOR[@]}" $INTERACTOR_RUN_COMMAND input log \
+      > /playground/judge/to_program < /playground/judge/to_interactor 2> "$RESULTS/interactor-$i.stderr"
+      mv -f log "$RESULTS/interactor-$i.log" 2> /dev/null) &
+    # shellcheck disable=SC2086
+    PHASE=run measure "$i" "$TIMEOUT" "${AS_PROGRAM[@]}" $RUN_COMMAND \
+      < /playground/judge/to_program > /playground/judge/to_interactor 2> "$RESULTS/$i.stderr"
+    wait
+  elif [ "$arguments" = "true" ]; then
+    # xargs only splits the arguments, running the program through it would hide its exit status
+    args=()
+    while IFS= read -r -d '' arg; do
+      args+=("$arg")
+    done < <(xargs -r printf '%s\0' < "$SUITE/tests/$i.input")
+    # shellcheck disable=SC2086
+    PHASE=run measure "$i" "$TIMEOUT" "${AS_PROGRAM[@]}" $RUN_COMMAND "${args[@]}" \
+      > "$RESULTS/$i.stdout" 2> "$RESULTS/$i.stderr"
+  else
+    # shellcheck disable=SC2086
+    PHASE=run measure "$i" "$TIMEOUT" "${AS_PROGRAM[@]}" $RUN_COMMAND \
+      < "$SUITE/tests/$i.input" > "$RESULTS/$i.stdout" 2> "$RESULTS/$i.stderr"
+  fi
+done
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -13,7 +17,10 @@ import (
 	"github.com/improbable-eng/grpc-web/go/grpcweb"
 	"github.com/soheilhy/cmux"
 	"github.com/spf13/cobra"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 var serveCmd = &cobra.Command{
@@ -22,9 +29,17 @@ var serveCmd = &cobra.Command{
 	Long:  `Starts the server and begins listening for requests`,
 	Run: func(cmd *cobra.Command, args []string) {
 		port, _ := cmd.Flags().GetString("port")
+		certFile, _ := cmd.Flags().GetString("tls-cert")
+		keyFile, _ := cmd.Flags().GetString("tls-key")
+		clientCAFile, _ := cmd.Flags().GetString("client-ca")
+
+		tlsConfig, err := serverTLSConfig(certFile, keyFile, clientCAFile)
+		if err != nil {
+			log.Fatal(err)
+		}
 
 		fmt.Println("Server started")
-		err := server(port)
+		err = server(port, tlsConfig, clientCAFile != "")
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -35,31 +50,85 @@ func init() {
 	rootCmd.AddCommand(serveCmd)
 	// Add flags specific to this command
 	serveCmd.Flags().StringP("port", "p", "", "Port to run the server on")
+	serveCmd.Flags().String("tls-cert", "", "PEM certificate file, serves TLS when set along with --tls-key")
+	serveCmd.Flags().String("tls-key", "", "PEM private key file of --tls-cert")
+	serveCmd.Flags().String("client-ca", "", "PEM CA bundle verifying the client certificates of judges, "+
+		"judges are refused without one, other clients are still accepted")
+}
+
+// serverTLSConfig returns nil when no certificate is given, to serve plaintext.
+func serverTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		if clientCAFile != "" {
+			return nil, errors.New("--client-ca requires --tls-cert and --tls-key")
+		}
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("--tls-cert and --tls-key must be set together")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %v", clientCAFile)
+		}
+		tlsConfig.ClientCAs = clientCAs
+		// browsers reach the same listener through grpc-web, so certificates can't be required of every
+		// client, the manager refuses judges without one instead
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
 }
 
-func server(port string) error {
+// server serves gRPC, grpc-web and the frontend on port. With requireJudgeCertificates judges must present
+// a client certificate verified by tlsConfig, which the gRPC handlers learn from tlsInfoCredentials.
+func server(port string, tlsConfig *tls.Config, requireJudgeCertificates bool) error {
 	addr := ":" + port
 	lis, err := net.Listen("tcp", addr)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
+	if tlsConfig != nil {
+		// terminating TLS before cmux lets gRPC and HTTP share the port as in plaintext
+		lis = tls.NewListener(lis, tlsConfig)
+		log.Println("Serving TLS")
+	}
 	m := cmux.New(lis)
 
 	grpcL := m.MatchWithWriters(cmux.HTTP2MatchHeaderFieldSendSettings("content-type", "application/grpc"))
-	httpL := m.Match(cmux.HTTP1Fast())
+	// over TLS browsers negotiate HTTP/2 as well, served as cleartext HTTP/2 once TLS is terminated
+	httpL := m.Match(cmux.HTTP1Fast(), cmux.HTTP2())
 
-	grpcServer := grpc.NewServer()
+	var options []grpc.ServerOption
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(tlsInfoCredentials{}))
+	}
+	grpcServer := grpc.NewServer(options...)
 	wrappedGrpc := grpcweb.WrapServer(grpcServer,
 		grpcweb.WithAllowedRequestHeaders([]string{"x-grpc-web", "content-type"}),
 	)
-	man, err := manager.NewManager()
+	man, err := manager.NewManager(requireJudgeCertificates)
 	if err != nil {
 		return err
 	}
 	proto.RegisterManagerServer(grpcServer, man)
+	go man.SweepExpiredLeases(context.Background())
 
 	httpServer := &http.Server{
-		Handler: http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		Handler: h2c.NewHandler(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
 			if wrappedGrpc.IsGrpcWebRequest(req) || wrappedGrpc.IsAcceptableGrpcCorsRequest(req) {
 				wrappedGrpc.ServeHTTP(resp, req)
 				return
@@ -70,7 +139,7 @@ func server(port string) error {
 				return
 			}
 			http.ServeFile(resp, req, "build/browser/index.html")
-		}),
+		}), &http2.Server{}),
 	}
 
 	go func() {
@@ -94,3 +163,39 @@ func server(port string) error {
 	}
 	return nil
 }
+
+// tlsInfoCredentials are the transport credentials of the gRPC server when TLS is terminated before cmux.
+// They do no handshake of their own and only pass the state of the TLS connection on to the handlers,
+// which would not see the client certificate otherwise.
+type tlsInfoCredentials struct{}
+
+func (tlsInfoCredentials) ClientHandshake(context.Context, string, net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("tlsInfoCredentials are server side only")
+}
+
+func (tlsInfoCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	inner := conn
+	if muxConn, ok := conn.(*cmux.MuxConn); ok {
+		inner = muxConn.Conn
+	}
+	tlsConn, ok := inner.(*tls.Conn)
+	if !ok {
+		return nil, nil, errors.New("connection is not TLS")
+	}
+	return conn, credentials.TLSInfo{
+		State:          tlsConn.ConnectionState(),
+		CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity},
+	}, nil
+}
+
+func (tlsInfoCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "tls"}
+}
+
+func (c tlsInfoCredentials) Clone() credentials.TransportCredentials {
+	return c
+}
+
+func (tlsInfoCredentials) OverrideServerName(string) error {
+	return nil
+}
@@ -6,8 +6,10 @@ import (
 )
 
 const (
-	JudgeTimeout     = 120 //seconds
-	MaxJudgeTryCount = 5
+	JudgeTimeout      = 120 //seconds, for submissions moved to judging without a lease
+	MaxJudgeTryCount  = 5
+	LeaseDuration     = 30            //seconds, renewed by the heartbeats of the judge
+	JudgeOfflineAfter = LeaseDuration //seconds without heartbeat
 )
 
 type dbConfig struct {
@@ -0,0 +1,2 @@
+ALTER TABLE submissions DROP COLUMN IF EXISTS failed_test;
+DROP TABLE IF exists test_cases;
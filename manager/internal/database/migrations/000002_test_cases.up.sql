@@ -0,0 +1,11 @@
+CREATE TABLE test_cases (
+    id SERIAL PRIMARY KEY,
+    question_id INTEGER NOT NULL REFERENCES questions(id) ON DELETE CASCADE,
+    input TEXT,
+    output TEXT,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX idx_test_cases_question ON test_cases (question_id);
+
+ALTER TABLE submissions ADD COLUMN failed_test INTEGER;
@@ -0,0 +1,4 @@
+ALTER TABLE questions ADD COLUMN input_mode INTEGER DEFAULT 1;
+
+-- questions created before stdin support read their input from command-line arguments
+UPDATE questions SET input_mode = 2;
@@ -0,0 +1 @@
+ALTER TABLE submissions ADD COLUMN language TEXT NOT NULL DEFAULT 'go';
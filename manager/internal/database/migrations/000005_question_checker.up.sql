@@ -0,0 +1,2 @@
+ALTER TABLE questions ADD COLUMN checker BYTEA;
+ALTER TABLE questions ADD COLUMN checker_language TEXT;
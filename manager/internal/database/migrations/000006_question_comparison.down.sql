@@ -0,0 +1,3 @@
+ALTER TABLE questions DROP COLUMN IF EXISTS relative_epsilon;
+ALTER TABLE questions DROP COLUMN IF EXISTS absolute_epsilon;
+ALTER TABLE questions DROP COLUMN IF EXISTS comparison_mode;
@@ -0,0 +1,3 @@
+ALTER TABLE questions ADD COLUMN comparison_mode INTEGER DEFAULT 1;
+ALTER TABLE questions ADD COLUMN absolute_epsilon DOUBLE PRECISION DEFAULT 0;
+ALTER TABLE questions ADD COLUMN relative_epsilon DOUBLE PRECISION DEFAULT 0;
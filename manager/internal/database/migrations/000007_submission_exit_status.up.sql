@@ -0,0 +1,2 @@
+ALTER TABLE submissions ADD COLUMN exit_code INTEGER;
+ALTER TABLE submissions ADD COLUMN exit_signal INTEGER;
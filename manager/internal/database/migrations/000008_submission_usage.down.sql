@@ -0,0 +1,3 @@
+ALTER TABLE submissions DROP COLUMN IF EXISTS memory;
+ALTER TABLE submissions DROP COLUMN IF EXISTS cpu_time;
+ALTER TABLE submissions DROP COLUMN IF EXISTS wall_time;
@@ -0,0 +1,3 @@
+ALTER TABLE submissions ADD COLUMN wall_time INTEGER;
+ALTER TABLE submissions ADD COLUMN cpu_time INTEGER;
+ALTER TABLE submissions ADD COLUMN memory BIGINT;
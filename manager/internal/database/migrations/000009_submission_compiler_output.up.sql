@@ -0,0 +1 @@
+ALTER TABLE submissions ADD COLUMN compiler_output TEXT;
@@ -0,0 +1,4 @@
+ALTER TABLE submissions DROP COLUMN IF EXISTS max_score;
+ALTER TABLE submissions DROP COLUMN IF EXISTS score;
+ALTER TABLE test_cases DROP COLUMN IF EXISTS subtask_id;
+DROP TABLE IF EXISTS subtasks;
@@ -0,0 +1,20 @@
+CREATE TABLE subtasks (
+    id SERIAL PRIMARY KEY,
+    question_id INTEGER NOT NULL REFERENCES questions(id) ON DELETE CASCADE,
+    points INTEGER NOT NULL,
+    scoring_mode INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX idx_subtasks_question ON subtasks (question_id);
+
+ALTER TABLE test_cases ADD COLUMN subtask_id INTEGER REFERENCES subtasks(id) ON DELETE SET NULL;
+
+ALTER TABLE submissions ADD COLUMN score INTEGER;
+ALTER TABLE submissions ADD COLUMN max_score INTEGER;
+
+-- verdicts (OK through RUNTIME_ERROR) were all or nothing out of 100, as score.go still scores questions
+-- without subtasks; pending, judging and failed submissions carry no score
+UPDATE submissions
+SET max_score = 100, score = CASE WHEN state = 3 THEN 100 ELSE 0 END
+WHERE state BETWEEN 3 AND 8;
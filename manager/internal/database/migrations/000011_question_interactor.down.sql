@@ -0,0 +1,2 @@
+ALTER TABLE questions DROP COLUMN IF EXISTS interactor_language;
+ALTER TABLE questions DROP COLUMN IF EXISTS interactor;
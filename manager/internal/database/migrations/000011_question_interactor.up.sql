@@ -0,0 +1,2 @@
+ALTER TABLE questions ADD COLUMN interactor BYTEA;
+ALTER TABLE questions ADD COLUMN interactor_language TEXT;
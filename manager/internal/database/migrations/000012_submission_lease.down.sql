@@ -0,0 +1,2 @@
+ALTER TABLE submissions DROP COLUMN IF EXISTS lease_expires_at;
+ALTER TABLE submissions DROP COLUMN IF EXISTS judge;
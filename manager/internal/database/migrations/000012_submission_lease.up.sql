@@ -0,0 +1,2 @@
+ALTER TABLE submissions ADD COLUMN judge TEXT;
+ALTER TABLE submissions ADD COLUMN lease_expires_at TIMESTAMPTZ;
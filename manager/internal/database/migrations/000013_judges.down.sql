@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_submissions_judge;
+DROP TABLE IF EXISTS judges;
@@ -0,0 +1,11 @@
+CREATE TABLE judges (
+    id TEXT PRIMARY KEY,
+    hostname TEXT NOT NULL DEFAULT '',
+    languages TEXT[] NOT NULL DEFAULT '{}',
+    capacity INTEGER NOT NULL DEFAULT 1,
+    disabled BOOLEAN NOT NULL DEFAULT false,
+    last_heartbeat_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX idx_submissions_judge ON submissions (judge, state);
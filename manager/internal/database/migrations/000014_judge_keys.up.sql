@@ -0,0 +1,8 @@
+-- keys are random, so a SHA-256 hash can be looked up directly and is enough to protect them
+CREATE TABLE judge_keys (
+    id SERIAL PRIMARY KEY,
+    judge_id TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    revoked_at TIMESTAMPTZ
+);
@@ -0,0 +1 @@
+ALTER TABLE questions ADD COLUMN output_limit BIGINT NOT NULL DEFAULT 0;
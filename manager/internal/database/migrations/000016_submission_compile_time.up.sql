@@ -0,0 +1 @@
+ALTER TABLE submissions ADD COLUMN compile_time INTEGER;
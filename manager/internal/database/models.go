@@ -1,6 +1,7 @@
 package database
 
 import (
+	"github.com/CT1403-2/Code-Judgement/proto"
 	"time"
 )
 
@@ -23,4 +24,9 @@ type submission struct {
 	retryCount     int32
 	state          int32
 	stateUpdatedAt *time.Time
+	judge          *string
+}
+
+func (s *submission) leasedTo(judge string) bool {
+	return s.state == int32(proto.SubmissionState_SUBMISSION_STATE_JUDGING) && s.judge != nil && *s.judge == judge
 }
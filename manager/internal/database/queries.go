@@ -2,7 +2,7 @@ package database
 
 const (
 	truncateAllTablesQuery = `
-		TRUNCATE TABLE submissions, questions, users, roles;`
+		TRUNCATE TABLE submissions, test_cases, subtasks, questions, users, roles, judges, judge_keys;`
 
 	createRolesQuery = `
 		INSERT INTO roles (role_type)
@@ -53,11 +53,20 @@ const (
 		SELECT count(*) FROM users`
 
 	getUserStatsQuery = `
-		SELECT 
-			COUNT(DISTINCT question_id) AS tried_count,
-			COUNT(DISTINCT CASE WHEN state = $2 THEN question_id END) AS success_count
-		FROM submissions
-		WHERE user_id = $1`
+		SELECT
+			COUNT(*) AS tried_count,
+			COUNT(*) FILTER (WHERE solved) AS success_count,
+			COALESCE(SUM(score), 0) AS score,
+			COALESCE(SUM(max_score), 0) AS max_score
+		FROM (
+			SELECT
+				MAX(score) AS score,
+				MAX(max_score) AS max_score,
+				BOOL_OR(state = $2) OR (MAX(max_score) > 0 AND MAX(score) >= MAX(max_score)) AS solved
+			FROM submissions
+			WHERE user_id = $1
+			GROUP BY question_id
+		) AS best`
 
 	getQuestionsCountQuery = `SELECT count(*) FROM questions`
 
@@ -91,7 +100,9 @@ const (
 		OFFSET $2 LIMIT $3`
 
 	getQuestionQuery = `
-		SELECT questions.id, title, statement, "input", "output", memory_limit, time_limit, state, username
+		SELECT questions.id, title, statement, "input", "output", memory_limit, time_limit, output_limit, state, username,
+			input_mode, checker, COALESCE(checker_language, ''), comparison_mode, absolute_epsilon, relative_epsilon,
+			interactor, COALESCE(interactor_language, '')
 		FROM questions 
 		JOIN users ON users.id = questions.owner
 		WHERE questions.id = $1`
@@ -102,33 +113,140 @@ const (
 		WHERE id = $1
 		`
 	createQuestionQuery = `
-		INSERT INTO questions (title, statement, owner, input, output, memory_limit, time_limit, state)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO questions (title, statement, owner, input, output, memory_limit, time_limit, state, input_mode,
+			checker, checker_language, comparison_mode, absolute_epsilon, relative_epsilon, interactor, interactor_language,
+			output_limit)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
 		RETURNING id`
 
-	createSubmissionQuery = `
-		INSERT INTO submissions (user_id, question_id, code, state)
+	getTestCasesQuery = `
+		SELECT id, question_id, input, output, subtask_id
+		FROM test_cases
+		WHERE question_id = $1
+		ORDER BY id ASC`
+
+	getTestCaseQuestionQuery = `
+		SELECT question_id FROM test_cases
+		WHERE id = $1`
+
+	createTestCaseQuery = `
+		INSERT INTO test_cases (question_id, input, output, subtask_id)
 		VALUES ($1, $2, $3, $4)
 		RETURNING id`
 
+	updateTestCaseQuery = `
+		UPDATE test_cases
+		SET input = $2, output = $3, subtask_id = $4
+		WHERE id = $1`
+
+	deleteTestCaseQuery = `
+		DELETE FROM test_cases
+		WHERE id = $1`
+
+	getSubtasksQuery = `
+		SELECT id, question_id, points, scoring_mode
+		FROM subtasks
+		WHERE question_id = $1
+		ORDER BY id ASC`
+
+	getSubtaskQuestionQuery = `
+		SELECT question_id FROM subtasks
+		WHERE id = $1`
+
+	createSubtaskQuery = `
+		INSERT INTO subtasks (question_id, points, scoring_mode)
+		VALUES ($1, $2, $3)
+		RETURNING id`
+
+	updateSubtaskQuery = `
+		UPDATE subtasks
+		SET points = $2, scoring_mode = $3
+		WHERE id = $1`
+
+	deleteSubtaskQuery = `
+		DELETE FROM subtasks
+		WHERE id = $1`
+
+	createSubmissionQuery = `
+		INSERT INTO submissions (user_id, question_id, code, state, language)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+
 	selectSubmissionForUpdateQuery = `
-		SELECT id, state, retry_count, state_updated_at
+		SELECT id, state, retry_count, state_updated_at, judge
 		FROM submissions
 		WHERE id = $1
 		FOR UPDATE`
 
-	updateSubmissionStateQuery = `
+	updateSubmissionResultQuery = `
 		UPDATE submissions
-		SET state = $2, retry_count = $3, state_updated_at = now()
+		SET state = $2, retry_count = $3, failed_test = $4, exit_code = $5, exit_signal = $6,
+			wall_time = $7, cpu_time = $8, memory = $9, compiler_output = $10, score = $11, max_score = $12,
+			compile_time = $13, lease_expires_at = NULL, state_updated_at = now()
 		WHERE id = $1
 		`
 
+	// SKIP LOCKED lets concurrent judges lease disjoint submissions without waiting on each other,
+	// the judge only leases submissions whose languages, checker and interactor included, are in $6
+	leaseSubmissionsQuery = `
+		WITH leased AS (
+			UPDATE submissions
+			SET state = $2, judge = $3, lease_expires_at = now() + make_interval(secs => $4),
+				state_updated_at = now()
+			WHERE id IN (
+				SELECT submissions.id FROM submissions
+				JOIN questions ON questions.id = submissions.question_id
+				WHERE submissions.state = $1
+					AND submissions.language = ANY($6)
+					AND COALESCE(NULLIF(questions.checker_language, ''), submissions.language) = ANY($6)
+					AND COALESCE(NULLIF(questions.interactor_language, ''), submissions.language) = ANY($6)
+				ORDER BY submissions.id ASC
+				LIMIT $5
+				FOR UPDATE OF submissions SKIP LOCKED
+			)
+			RETURNING id, code, question_id, state, language, judge, lease_expires_at
+		)
+		SELECT * FROM leased
+		ORDER BY id ASC`
+
+	renewLeasesQuery = `
+		UPDATE submissions
+		SET lease_expires_at = now() + make_interval(secs => $4)
+		WHERE state = $1 AND judge = $2 AND id = ANY($3)
+		RETURNING id, lease_expires_at`
+
+	// submissions moved to judging without a lease expire JudgeTimeout after the state change
+	requeueExpiredLeasesQuery = `
+		UPDATE submissions
+		SET state = CASE WHEN retry_count + 1 >= $4 THEN $3 ELSE $2 END, retry_count = retry_count + 1,
+			lease_expires_at = NULL, state_updated_at = now()
+		WHERE id IN (
+			SELECT id FROM submissions
+			WHERE state = $1
+				AND COALESCE(lease_expires_at, state_updated_at + make_interval(secs => $5)) < now()
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, state`
+
+	getSubmissionQuery = `
+		SELECT id, code, question_id, state, failed_test, language, exit_code, exit_signal,
+			wall_time, cpu_time, memory, compile_time, score, max_score, compiler_output, judge, user_id
+		FROM submissions
+		WHERE id = $1`
+
 	getSubmissionsWithStateCountQuery = `
 		SELECT count(*) FROM submissions
 		WHERE state = $1`
 
+	getPendingSubmissionIdsQuery = `
+		SELECT id FROM submissions
+		WHERE state = $1
+		ORDER BY id ASC
+		LIMIT $2`
+
 	getSubmissionsWithStateQuery = `
-		SELECT id, code, question_id, state
+		SELECT id, code, question_id, state, failed_test, language, exit_code, exit_signal,
+			wall_time, cpu_time, memory, compile_time, score, max_score
 		FROM submissions 
 		WHERE state = $1
 		ORDER BY id ASC
@@ -138,7 +256,8 @@ const (
 		SELECT count(*) FROM submissions 
 		WHERE user_id = $1 and question_id = $2`
 	getUserQuestionSubmissionsQuery = `
-		SELECT id, code, question_id, state
+		SELECT id, code, question_id, state, failed_test, language, exit_code, exit_signal,
+			wall_time, cpu_time, memory, compile_time, score, max_score
 		FROM submissions 
 		WHERE user_id = $1 and question_id = $2
 		ORDER BY id
@@ -149,9 +268,63 @@ const (
 		WHERE user_id = $1`
 
 	getUserAllSubmissionsQuery = `
-		SELECT id, code, question_id, state
+		SELECT id, code, question_id, state, failed_test, language, exit_code, exit_signal,
+			wall_time, cpu_time, memory, compile_time, score, max_score
 		FROM submissions
 		WHERE user_id = $1
 		ORDER BY id
 		OFFSET $2 LIMIT $3`
+
+	registerJudgeQuery = `
+		INSERT INTO judges (id, hostname, languages, capacity, last_heartbeat_at)
+		VALUES ($1, $2, $3, $4, now())
+		ON CONFLICT (id) DO UPDATE
+		SET hostname = EXCLUDED.hostname, languages = EXCLUDED.languages, capacity = EXCLUDED.capacity,
+			last_heartbeat_at = now()`
+
+	getJudgeLanguagesQuery = `
+		SELECT DISTINCT unnest(languages) AS language FROM judges
+		WHERE NOT disabled
+		ORDER BY language ASC`
+
+	// current jobs are the submissions the judge is judging, throughput the ones it judged in the last hour
+	getJudgesQuery = `
+		SELECT judges.id, hostname, languages, capacity, disabled, last_heartbeat_at,
+			COUNT(submissions.id) FILTER (WHERE submissions.state = $1) AS current_jobs,
+			COUNT(submissions.id) FILTER (WHERE submissions.state NOT IN ($1, $2)
+				AND submissions.state_updated_at > now() - interval '1 hour') AS judged_last_hour
+		FROM judges
+		LEFT JOIN submissions ON submissions.judge = judges.id
+		WHERE $3 = '' OR judges.id = $3
+		GROUP BY judges.id
+		ORDER BY judges.id ASC`
+
+	updateJudgeDisabledQuery = `
+		UPDATE judges
+		SET disabled = $2
+		WHERE id = $1`
+
+	updateJudgeHeartbeatQuery = `
+		UPDATE judges
+		SET last_heartbeat_at = now()
+		WHERE id = $1`
+
+	createJudgeKeyQuery = `
+		INSERT INTO judge_keys (judge_id, key_hash)
+		VALUES ($1, $2)
+		RETURNING id, created_at`
+
+	revokeJudgeKeyQuery = `
+		UPDATE judge_keys
+		SET revoked_at = now()
+		WHERE id = $1 AND revoked_at IS NULL`
+
+	getJudgeKeysQuery = `
+		SELECT id, judge_id, created_at, revoked_at IS NOT NULL
+		FROM judge_keys
+		ORDER BY id ASC`
+
+	getJudgeKeyOwnerQuery = `
+		SELECT judge_id FROM judge_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL`
 )
@@ -9,8 +9,11 @@ import (
 	"github.com/stretchr/testify/require"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
+	"sync"
 	"testing"
+	"time"
 )
 
 func newRepository(truncate bool) (*postgresqlRepository, error) {
@@ -189,6 +192,7 @@ func TestQuestion(t *testing.T) {
 	output := "this is test question output"
 	memoryLimit := int64(1024) //MB
 	timeLimit := int64(1)      //seconds
+	outputLimit := int64(2048) //KB
 	question := &proto.Question{
 		Title:     title,
 		Statement: statement,
@@ -197,6 +201,7 @@ func TestQuestion(t *testing.T) {
 		Limitations: &proto.Limitations{
 			Memory:   memoryLimit,
 			Duration: timeLimit,
+			Output:   outputLimit,
 		},
 	}
 	title2 := "Test Question2"
@@ -220,8 +225,11 @@ func TestQuestion(t *testing.T) {
 		require.Equal(t, question.Output, q.Output)
 		require.Equal(t, question.Limitations.Duration, q.Limitations.Duration)
 		require.Equal(t, question.Limitations.Memory, q.Limitations.Memory)
+		require.Equal(t, question.Limitations.Output, q.Limitations.Output)
 		require.Equal(t, proto.QuestionState_QUESTION_STATE_DRAFT, q.State)
 		require.Equal(t, username, q.Owner)
+		require.Equal(t, proto.InputMode_INPUT_MODE_STDIN, q.InputMode)
+		require.Equal(t, proto.ComparisonMode_COMPARISON_MODE_EXACT, q.ComparisonMode)
 	})
 
 	questionId2, err := repo.CreateQuestion(repo.ctx, userId2, question2)
@@ -295,6 +303,53 @@ func TestQuestion(t *testing.T) {
 		require.Equal(t, oldTitle, q.Title)
 	})
 
+	t.Run("test edit question checker success", func(t *testing.T) {
+		qIdStr := fmt.Sprintf("%v", questionId2)
+		checker := []byte("int main() { return 0; }")
+		q := &proto.Question{Id: &qIdStr, Checker: checker, CheckerLanguage: "cpp"}
+		err := repo.EditQuestion(repo.ctx, q)
+		require.NoError(t, err)
+		q, err = repo.GetQuestion(repo.ctx, int(questionId2))
+		require.NoError(t, err)
+		require.Equal(t, checker, q.Checker)
+		require.Equal(t, "cpp", q.CheckerLanguage)
+	})
+
+	t.Run("test edit question interactor success", func(t *testing.T) {
+		qIdStr := fmt.Sprintf("%v", questionId2)
+		interactor := []byte("print(input())")
+		q := &proto.Question{Id: &qIdStr, Interactor: interactor, InteractorLanguage: "python"}
+		err := repo.EditQuestion(repo.ctx, q)
+		require.NoError(t, err)
+		q, err = repo.GetQuestion(repo.ctx, int(questionId2))
+		require.NoError(t, err)
+		require.Equal(t, interactor, q.Interactor)
+		require.Equal(t, "python", q.InteractorLanguage)
+	})
+
+	t.Run("test edit question comparison mode success", func(t *testing.T) {
+		qIdStr := fmt.Sprintf("%v", questionId2)
+		q := &proto.Question{Id: &qIdStr, ComparisonMode: proto.ComparisonMode_COMPARISON_MODE_FLOAT,
+			AbsoluteEpsilon: 1e-6, RelativeEpsilon: 1e-9}
+		err := repo.EditQuestion(repo.ctx, q)
+		require.NoError(t, err)
+		q, err = repo.GetQuestion(repo.ctx, int(questionId2))
+		require.NoError(t, err)
+		require.Equal(t, proto.ComparisonMode_COMPARISON_MODE_FLOAT, q.ComparisonMode)
+		require.Equal(t, 1e-6, q.AbsoluteEpsilon)
+		require.Equal(t, 1e-9, q.RelativeEpsilon)
+	})
+
+	t.Run("test edit question input mode success", func(t *testing.T) {
+		qIdStr := fmt.Sprintf("%v", questionId2)
+		q := &proto.Question{Id: &qIdStr, InputMode: proto.InputMode_INPUT_MODE_ARGUMENTS}
+		err := repo.EditQuestion(repo.ctx, q)
+		require.NoError(t, err)
+		q, err = repo.GetQuestion(repo.ctx, int(questionId2))
+		require.NoError(t, err)
+		require.Equal(t, proto.InputMode_INPUT_MODE_ARGUMENTS, q.InputMode)
+	})
+
 	t.Run("test questions pagination", func(t *testing.T) {
 		for i := 0; i < 20; i++ {
 			q := &proto.Question{
@@ -330,6 +385,151 @@ func TestQuestion(t *testing.T) {
 	})
 }
 
+func TestTestCase(t *testing.T) {
+	repo, err := newRepository(true)
+	require.NoError(t, err)
+	err = repo.SetUp()
+	userId, err := repo.CreateMember(repo.ctx, "username", "password")
+	require.NoError(t, err)
+	require.NotZero(t, userId)
+
+	questionId, err := repo.CreateQuestion(repo.ctx, userId, &proto.Question{Title: "Test Question"})
+	require.NoError(t, err)
+	require.NotZero(t, questionId)
+	questionIdStr := fmt.Sprintf("%v", questionId)
+
+	var firstId, secondId int32
+	t.Run("create test cases success", func(t *testing.T) {
+		firstId, err = repo.CreateTestCase(repo.ctx, int(questionId), &proto.TestCase{Input: "1 2", Output: "3"})
+		require.NoError(t, err)
+		require.NotZero(t, firstId)
+		secondId, err = repo.CreateTestCase(repo.ctx, int(questionId), &proto.TestCase{Input: "2\n3", Output: "5"})
+		require.NoError(t, err)
+		require.NotZero(t, secondId)
+	})
+
+	t.Run("create test case fail, question not found", func(t *testing.T) {
+		_, err := repo.CreateTestCase(repo.ctx, -1, &proto.TestCase{Input: "1 2", Output: "3"})
+		require.Error(t, err)
+	})
+
+	t.Run("get test cases success", func(t *testing.T) {
+		testCases, err := repo.GetTestCases(repo.ctx, int(questionId))
+		require.NoError(t, err)
+		require.Len(t, testCases, 2)
+		require.Equal(t, fmt.Sprintf("%v", firstId), testCases[0].GetId())
+		require.Equal(t, questionIdStr, testCases[0].QuestionId)
+		require.Equal(t, "1 2", testCases[0].Input)
+		require.Equal(t, "3", testCases[0].Output)
+		require.Equal(t, "2\n3", testCases[1].Input)
+	})
+
+	t.Run("get test case question success", func(t *testing.T) {
+		qId, err := repo.GetTestCaseQuestion(repo.ctx, int(secondId))
+		require.NoError(t, err)
+		require.Equal(t, int(questionId), qId)
+	})
+
+	t.Run("edit test case success", func(t *testing.T) {
+		err := repo.EditTestCase(repo.ctx, int(secondId), &proto.TestCase{Input: "4 5", Output: "9"})
+		require.NoError(t, err)
+		testCases, err := repo.GetTestCases(repo.ctx, int(questionId))
+		require.NoError(t, err)
+		require.Equal(t, "4 5", testCases[1].Input)
+		require.Equal(t, "9", testCases[1].Output)
+	})
+
+	t.Run("delete test case success", func(t *testing.T) {
+		err := repo.DeleteTestCase(repo.ctx, int(firstId))
+		require.NoError(t, err)
+		testCases, err := repo.GetTestCases(repo.ctx, int(questionId))
+		require.NoError(t, err)
+		require.Len(t, testCases, 1)
+	})
+
+	t.Run("delete test case fail, test case not found", func(t *testing.T) {
+		err := repo.DeleteTestCase(repo.ctx, int(firstId))
+		require.Equal(t, pgx.ErrNoRows, err)
+	})
+}
+
+func TestSubtask(t *testing.T) {
+	repo, err := newRepository(true)
+	require.NoError(t, err)
+	err = repo.SetUp()
+	userId, err := repo.CreateMember(repo.ctx, "username", "password")
+	require.NoError(t, err)
+	require.NotZero(t, userId)
+
+	questionId, err := repo.CreateQuestion(repo.ctx, userId, &proto.Question{Title: "Test Question"})
+	require.NoError(t, err)
+	require.NotZero(t, questionId)
+
+	var subtaskId int32
+	t.Run("create subtask success", func(t *testing.T) {
+		subtaskId, err = repo.CreateSubtask(repo.ctx, int(questionId), &proto.Subtask{Points: 30})
+		require.NoError(t, err)
+		require.NotZero(t, subtaskId)
+	})
+
+	t.Run("create subtask fail, question not found", func(t *testing.T) {
+		_, err := repo.CreateSubtask(repo.ctx, -1, &proto.Subtask{Points: 30})
+		require.Error(t, err)
+	})
+
+	t.Run("get subtasks success", func(t *testing.T) {
+		subtasks, err := repo.GetSubtasks(repo.ctx, int(questionId))
+		require.NoError(t, err)
+		require.Len(t, subtasks, 1)
+		require.Equal(t, fmt.Sprintf("%v", subtaskId), subtasks[0].GetId())
+		require.Equal(t, int32(30), subtasks[0].Points)
+		require.Equal(t, proto.ScoringMode_SCORING_MODE_ALL_OR_NOTHING, subtasks[0].ScoringMode)
+	})
+
+	t.Run("get subtask question success", func(t *testing.T) {
+		qId, err := repo.GetSubtaskQuestion(repo.ctx, int(subtaskId))
+		require.NoError(t, err)
+		require.Equal(t, int(questionId), qId)
+	})
+
+	t.Run("create test case in subtask success", func(t *testing.T) {
+		subtaskIdStr := fmt.Sprintf("%v", subtaskId)
+		testCaseId, err := repo.CreateTestCase(repo.ctx, int(questionId),
+			&proto.TestCase{Input: "1 2", Output: "3", SubtaskId: &subtaskIdStr})
+		require.NoError(t, err)
+		require.NotZero(t, testCaseId)
+		testCases, err := repo.GetTestCases(repo.ctx, int(questionId))
+		require.NoError(t, err)
+		require.Equal(t, subtaskIdStr, testCases[0].GetSubtaskId())
+	})
+
+	t.Run("edit subtask success", func(t *testing.T) {
+		err := repo.EditSubtask(repo.ctx, int(subtaskId),
+			&proto.Subtask{Points: 50, ScoringMode: proto.ScoringMode_SCORING_MODE_PER_TEST})
+		require.NoError(t, err)
+		subtasks, err := repo.GetSubtasks(repo.ctx, int(questionId))
+		require.NoError(t, err)
+		require.Equal(t, int32(50), subtasks[0].Points)
+		require.Equal(t, proto.ScoringMode_SCORING_MODE_PER_TEST, subtasks[0].ScoringMode)
+	})
+
+	t.Run("delete subtask success", func(t *testing.T) {
+		err := repo.DeleteSubtask(repo.ctx, int(subtaskId))
+		require.NoError(t, err)
+		subtasks, err := repo.GetSubtasks(repo.ctx, int(questionId))
+		require.NoError(t, err)
+		require.Len(t, subtasks, 0)
+		testCases, err := repo.GetTestCases(repo.ctx, int(questionId))
+		require.NoError(t, err)
+		require.Nil(t, testCases[0].SubtaskId)
+	})
+
+	t.Run("delete subtask fail, subtask not found", func(t *testing.T) {
+		err := repo.DeleteSubtask(repo.ctx, int(subtaskId))
+		require.Equal(t, pgx.ErrNoRows, err)
+	})
+}
+
 func TestSubmission(t *testing.T) {
 	repo, err := newRepository(true)
 	require.NoError(t, err)
@@ -365,20 +565,21 @@ func TestSubmission(t *testing.T) {
 	pageSize := 10
 	//code := make([]byte, 0)
 	var code []byte
+	judgeLanguages := []string{"go"}
 	t.Run("test submit fail, question not found", func(t *testing.T) {
 		wrongQuestionId := int32(-1)
-		err := repo.CreateSubmission(repo.ctx, userId, wrongQuestionId, code)
+		err := repo.CreateSubmission(repo.ctx, userId, wrongQuestionId, code, "go")
 		require.Error(t, err)
 	})
 
 	t.Run("test submit fail, user not found", func(t *testing.T) {
 		wrongUserId := int32(-1)
-		err := repo.CreateSubmission(repo.ctx, wrongUserId, questionId, code)
+		err := repo.CreateSubmission(repo.ctx, wrongUserId, questionId, code, "go")
 		require.Error(t, err)
 	})
 
 	t.Run("test submit success", func(t *testing.T) {
-		err := repo.CreateSubmission(repo.ctx, userId, questionId, code)
+		err := repo.CreateSubmission(repo.ctx, userId, questionId, code, "go")
 		require.NoError(t, err)
 	})
 
@@ -400,6 +601,7 @@ func TestSubmission(t *testing.T) {
 		s := submissions[0]
 		require.Equal(t, questionIdStr, s.QuestionId)
 		require.Equal(t, proto.SubmissionState_SUBMISSION_STATE_PENDING, *s.State)
+		require.Equal(t, "go", s.Language)
 	})
 
 	question2 := &proto.Question{}
@@ -407,7 +609,7 @@ func TestSubmission(t *testing.T) {
 	require.NoError(t, err)
 	require.NotZero(t, qId2)
 
-	err = repo.CreateSubmission(repo.ctx, userId, qId2, code)
+	err = repo.CreateSubmission(repo.ctx, userId, qId2, code, "go")
 	require.NoError(t, err)
 
 	t.Run("test get user all submissions success", func(t *testing.T) {
@@ -435,8 +637,8 @@ func TestSubmission(t *testing.T) {
 	})
 
 	t.Run("test judge timeout reset state success", func(t *testing.T) {
-		submitQuery := `INSERT INTO submissions (question_id, user_id, state)
-			VALUES ($1, $2, $3) RETURNING id`
+		submitQuery := `INSERT INTO submissions (question_id, user_id, state, lease_expires_at)
+			VALUES ($1, $2, $3, now() - interval '1 second') RETURNING id`
 		var subId int32
 
 		err := repo.pool.QueryRow(repo.ctx, submitQuery, questionId, userId,
@@ -444,8 +646,9 @@ func TestSubmission(t *testing.T) {
 		require.NoError(t, err)
 		require.NotZero(t, subId)
 
-		err = repo.handleSubmissionJudgingTimeout(repo.ctx, 2, subId)
+		requeued, err := repo.RequeueExpiredLeases(repo.ctx)
 		require.NoError(t, err)
+		require.Equal(t, 1, requeued)
 		var state, retryCount int
 		err = repo.pool.QueryRow(repo.ctx,
 			`SELECT state, retry_count FROM submissions WHERE id = $1`, subId).Scan(&state, &retryCount)
@@ -455,8 +658,8 @@ func TestSubmission(t *testing.T) {
 	})
 
 	t.Run("test judge reach max timeout, set state to failed", func(t *testing.T) {
-		submitQuery := `INSERT INTO submissions (question_id, user_id, state, retry_count)
-			VALUES ($1, $2, $3, $4) RETURNING id`
+		submitQuery := `INSERT INTO submissions (question_id, user_id, state, retry_count, lease_expires_at)
+			VALUES ($1, $2, $3, $4, now() - interval '1 second') RETURNING id`
 		var subId int32
 
 		err := repo.pool.QueryRow(repo.ctx, submitQuery, questionId, userId,
@@ -464,8 +667,9 @@ func TestSubmission(t *testing.T) {
 		require.NoError(t, err)
 		require.NotZero(t, subId)
 
-		err = repo.handleSubmissionJudgingTimeout(repo.ctx, 2, subId)
+		requeued, err := repo.RequeueExpiredLeases(repo.ctx)
 		require.NoError(t, err)
+		require.Zero(t, requeued)
 		var state, retryCount int
 		err = repo.pool.QueryRow(repo.ctx,
 			`SELECT state, retry_count FROM submissions WHERE id = $1`, subId).Scan(&state, &retryCount)
@@ -501,4 +705,518 @@ func TestSubmission(t *testing.T) {
 		}
 	})
 
+	t.Run("test update submission result success", func(t *testing.T) {
+		submissions, _, err := repo.GetUserSubmissions(repo.ctx, userId, qId2, true, pageNumber, pageSize)
+		require.NoError(t, err)
+		require.Len(t, submissions, 1)
+
+		s := submissions[0]
+		sId, err := strconv.Atoi(*s.Id)
+		require.NoError(t, err)
+		state := proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER
+		failedTest := int32(3)
+		wallTime, cpuTime, memory := int32(120), int32(95), int64(2048)
+		compileTime := int32(830)
+		updated, err := repo.UpdateSubmissionResult(repo.ctx, int32(sId), &proto.Submission{
+			State:       &state,
+			FailedTest:  &failedTest,
+			WallTime:    &wallTime,
+			CpuTime:     &cpuTime,
+			Memory:      &memory,
+			CompileTime: &compileTime,
+		})
+		require.NoError(t, err)
+		require.True(t, updated)
+
+		submissions, _, err = repo.GetUserSubmissions(repo.ctx, userId, qId2, true, pageNumber, pageSize)
+		require.NoError(t, err)
+		require.Equal(t, state, submissions[0].GetState())
+		require.Equal(t, failedTest, submissions[0].GetFailedTest())
+		require.Equal(t, wallTime, submissions[0].GetWallTime())
+		require.Equal(t, cpuTime, submissions[0].GetCpuTime())
+		require.Equal(t, memory, submissions[0].GetMemory())
+		require.Equal(t, compileTime, submissions[0].GetCompileTime())
+		require.Nil(t, submissions[0].ExitCode)
+		require.Nil(t, submissions[0].Signal)
+	})
+
+	t.Run("test update submission runtime error success", func(t *testing.T) {
+		submissions, _, err := repo.GetUserSubmissions(repo.ctx, userId, qId2, true, pageNumber, pageSize)
+		require.NoError(t, err)
+		require.Len(t, submissions, 1)
+
+		sId, err := strconv.Atoi(*submissions[0].Id)
+		require.NoError(t, err)
+		state := proto.SubmissionState_SUBMISSION_STATE_RUNTIME_ERROR
+		failedTest := int32(1)
+		signal := int32(11)
+		updated, err := repo.UpdateSubmissionResult(repo.ctx, int32(sId),
+			&proto.Submission{State: &state, FailedTest: &failedTest, Signal: &signal})
+		require.NoError(t, err)
+		require.True(t, updated)
+
+		submissions, _, err = repo.GetUserSubmissions(repo.ctx, userId, qId2, true, pageNumber, pageSize)
+		require.NoError(t, err)
+		require.Equal(t, state, submissions[0].GetState())
+		require.Equal(t, signal, submissions[0].GetSignal())
+		require.Nil(t, submissions[0].ExitCode)
+	})
+
+	t.Run("test get submission compiler output success", func(t *testing.T) {
+		submissions, _, err := repo.GetUserSubmissions(repo.ctx, userId, qId2, true, pageNumber, pageSize)
+		require.NoError(t, err)
+		require.Len(t, submissions, 1)
+
+		sId, err := strconv.Atoi(*submissions[0].Id)
+		require.NoError(t, err)
+		state := proto.SubmissionState_SUBMISSION_STATE_COMPILE_ERROR
+		compilerOutput := "./main.go:4:2: undefined: x"
+		updated, err := repo.UpdateSubmissionResult(repo.ctx, int32(sId),
+			&proto.Submission{State: &state, CompilerOutput: &compilerOutput})
+		require.NoError(t, err)
+		require.True(t, updated)
+
+		submission, submitter, err := repo.GetSubmission(repo.ctx, int32(sId))
+		require.NoError(t, err)
+		require.Equal(t, userId, submitter)
+		require.Equal(t, state, submission.GetState())
+		require.Equal(t, compilerOutput, submission.GetCompilerOutput())
+	})
+
+	t.Run("test get submission not found", func(t *testing.T) {
+		_, _, err := repo.GetSubmission(repo.ctx, -1)
+		require.Equal(t, pgx.ErrNoRows, err)
+	})
+
+	t.Run("test user stats use best score", func(t *testing.T) {
+		submissions, _, err := repo.GetUserSubmissions(repo.ctx, userId, qId2, true, pageNumber, pageSize)
+		require.NoError(t, err)
+		require.Len(t, submissions, 1)
+
+		sId, err := strconv.Atoi(*submissions[0].Id)
+		require.NoError(t, err)
+		state := proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER
+		score, maxScore := int32(40), int32(100)
+		updated, err := repo.UpdateSubmissionResult(repo.ctx, int32(sId),
+			&proto.Submission{State: &state, Score: &score, MaxScore: &maxScore})
+		require.NoError(t, err)
+		require.True(t, updated)
+
+		submissions, _, err = repo.GetUserSubmissions(repo.ctx, userId, qId2, true, pageNumber, pageSize)
+		require.NoError(t, err)
+		require.Equal(t, score, submissions[0].GetScore())
+		require.Equal(t, maxScore, submissions[0].GetMaxScore())
+
+		stats, err := repo.GetUserStats(repo.ctx, userId)
+		require.NoError(t, err)
+		require.Equal(t, int64(score), stats.Score)
+		require.Equal(t, int64(maxScore), stats.MaxScore)
+	})
+
+	t.Run("test lease submissions", func(t *testing.T) {
+		_, _, err := repo.LeaseSubmissions(repo.ctx, "drain", judgeLanguages, 100)
+		require.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, repo.CreateSubmission(repo.ctx, userId, questionId, code, "go"))
+		}
+
+		leased, leaseExpiresAt, err := repo.LeaseSubmissions(repo.ctx, "judge-a", judgeLanguages, 2)
+		require.NoError(t, err)
+		require.Len(t, leased, 2)
+		require.True(t, leaseExpiresAt.After(time.Now()))
+		for _, s := range leased {
+			require.Equal(t, proto.SubmissionState_SUBMISSION_STATE_JUDGING, s.GetState())
+			require.Equal(t, "judge-a", s.GetJudge())
+		}
+
+		rest, _, err := repo.LeaseSubmissions(repo.ctx, "judge-b", judgeLanguages, 5)
+		require.NoError(t, err)
+		require.Len(t, rest, 1)
+		require.NotEqual(t, leased[0].GetId(), rest[0].GetId())
+		require.NotEqual(t, leased[1].GetId(), rest[0].GetId())
+
+		none, _, err := repo.LeaseSubmissions(repo.ctx, "judge-b", judgeLanguages, 5)
+		require.NoError(t, err)
+		require.Len(t, none, 0)
+
+		sId, err := strconv.Atoi(leased[0].GetId())
+		require.NoError(t, err)
+		state := proto.SubmissionState_SUBMISSION_STATE_OK
+		otherJudge, leaseHolder := "judge-b", "judge-a"
+		updated, err := repo.UpdateSubmissionResult(repo.ctx, int32(sId),
+			&proto.Submission{State: &state, Judge: &otherJudge})
+		require.NoError(t, err)
+		require.False(t, updated)
+		updated, err = repo.UpdateSubmissionResult(repo.ctx, int32(sId),
+			&proto.Submission{State: &state, Judge: &leaseHolder})
+		require.NoError(t, err)
+		require.True(t, updated)
+	})
+
+	t.Run("test concurrent leases are disjoint", func(t *testing.T) {
+		submissionCount, judgeCount := 20, 4
+		for i := 0; i < submissionCount; i++ {
+			require.NoError(t, repo.CreateSubmission(repo.ctx, userId, questionId, code, "go"))
+		}
+
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		leasedBy := make(map[string]string)
+		duplicates := 0
+		for i := 0; i < judgeCount; i++ {
+			judge := fmt.Sprintf("judge-%d", i)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					leased, _, err := repo.LeaseSubmissions(repo.ctx, judge, judgeLanguages, 1)
+					if err != nil || len(leased) == 0 {
+						return
+					}
+					mu.Lock()
+					if _, ok := leasedBy[leased[0].GetId()]; ok {
+						duplicates++
+					}
+					leasedBy[leased[0].GetId()] = judge
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		require.Zero(t, duplicates)
+		require.Len(t, leasedBy, submissionCount)
+	})
+
+	t.Run("test renew leases", func(t *testing.T) {
+		_, _, err := repo.LeaseSubmissions(repo.ctx, "drain", judgeLanguages, 100)
+		require.NoError(t, err)
+		require.NoError(t, repo.CreateSubmission(repo.ctx, userId, questionId, code, "go"))
+		leased, _, err := repo.LeaseSubmissions(repo.ctx, "judge-a", judgeLanguages, 1)
+		require.NoError(t, err)
+		require.Len(t, leased, 1)
+		sId, err := strconv.Atoi(leased[0].GetId())
+		require.NoError(t, err)
+
+		renewed, leaseExpiresAt, err := repo.RenewLeases(repo.ctx, "judge-b", []int32{int32(sId)})
+		require.NoError(t, err)
+		require.Len(t, renewed, 0)
+
+		_, err = repo.pool.Exec(repo.ctx, `UPDATE submissions SET lease_expires_at = now() WHERE id = $1`, sId)
+		require.NoError(t, err)
+		renewed, leaseExpiresAt, err = repo.RenewLeases(repo.ctx, "judge-a", []int32{int32(sId), -1})
+		require.NoError(t, err)
+		require.Equal(t, []int32{int32(sId)}, renewed)
+		require.True(t, leaseExpiresAt.After(time.Now().Add(LeaseDuration*time.Second/2)))
+
+		requeued, err := repo.RequeueExpiredLeases(repo.ctx)
+		require.NoError(t, err)
+		require.Zero(t, requeued)
+	})
+
+	t.Run("test lease only supported languages", func(t *testing.T) {
+		_, _, err := repo.LeaseSubmissions(repo.ctx, "drain", []string{"go", "python", "java"}, 100)
+		require.NoError(t, err)
+		checkedId, err := repo.CreateQuestion(repo.ctx, userId, &proto.Question{
+			Title: "Checked", Checker: []byte("checker"), CheckerLanguage: "java",
+		})
+		require.NoError(t, err)
+		require.NoError(t, repo.CreateSubmission(repo.ctx, userId, questionId, code, "python"))
+		require.NoError(t, repo.CreateSubmission(repo.ctx, userId, checkedId, code, "go"))
+
+		none, _, err := repo.LeaseSubmissions(repo.ctx, "judge-a", judgeLanguages, 5)
+		require.NoError(t, err)
+		require.Len(t, none, 0)
+
+		leased, _, err := repo.LeaseSubmissions(repo.ctx, "judge-a", []string{"go", "java"}, 5)
+		require.NoError(t, err)
+		require.Len(t, leased, 1)
+		require.Equal(t, "go", leased[0].GetLanguage())
+		require.Equal(t, strconv.Itoa(int(checkedId)), leased[0].GetQuestionId())
+
+		leased, _, err = repo.LeaseSubmissions(repo.ctx, "judge-b", []string{"python"}, 5)
+		require.NoError(t, err)
+		require.Len(t, leased, 1)
+		require.Equal(t, "python", leased[0].GetLanguage())
+	})
+
+	t.Run("test pending submission ids", func(t *testing.T) {
+		_, _, err := repo.LeaseSubmissions(repo.ctx, "drain", []string{"go", "python", "java"}, 100)
+		require.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, repo.CreateSubmission(repo.ctx, userId, questionId, code, "go"))
+		}
+
+		ids, err := repo.GetPendingSubmissionIds(repo.ctx, 2)
+		require.NoError(t, err)
+		require.Len(t, ids, 2)
+
+		leased, _, err := repo.LeaseSubmissions(repo.ctx, "judge-a", judgeLanguages, 1)
+		require.NoError(t, err)
+		require.Equal(t, ids[0], leased[0].GetId())
+		ids, err = repo.GetPendingSubmissionIds(repo.ctx, 5)
+		require.NoError(t, err)
+		require.Len(t, ids, 2)
+		require.NotContains(t, ids, leased[0].GetId())
+	})
+}
+
+func TestJudge(t *testing.T) {
+	repo, err := newRepository(true)
+	require.NoError(t, err)
+	err = repo.SetUp()
+	require.NoError(t, err)
+	userId, err := repo.CreateMember(repo.ctx, "username", "password")
+	require.NoError(t, err)
+	questionId, err := repo.CreateQuestion(repo.ctx, userId, &proto.Question{})
+	require.NoError(t, err)
+
+	judge := &proto.Judge{Id: "judge-1", Hostname: "host-1", Languages: []string{"c", "go"}, Capacity: 2}
+
+	t.Run("register judge success", func(t *testing.T) {
+		err := repo.RegisterJudge(repo.ctx, judge)
+		require.NoError(t, err)
+
+		registered, err := repo.GetJudge(repo.ctx, judge.Id)
+		require.NoError(t, err)
+		require.Equal(t, judge.Hostname, registered.Hostname)
+		require.Equal(t, judge.Languages, registered.Languages)
+		require.Equal(t, judge.Capacity, registered.Capacity)
+		require.Equal(t, proto.JudgeStatus_JUDGE_STATUS_ONLINE, registered.Status)
+		require.NotZero(t, registered.LastHeartbeat)
+	})
+
+	t.Run("register judge again updates it", func(t *testing.T) {
+		judge.Capacity = 4
+		err := repo.RegisterJudge(repo.ctx, judge)
+		require.NoError(t, err)
+
+		judges, err := repo.GetJudges(repo.ctx)
+		require.NoError(t, err)
+		require.Len(t, judges, 1)
+		require.Equal(t, int32(4), judges[0].Capacity)
+	})
+
+	t.Run("get judge fail, judge not found", func(t *testing.T) {
+		_, err := repo.GetJudge(repo.ctx, "unknown")
+		require.Equal(t, pgx.ErrNoRows, err)
+	})
+
+	t.Run("judge jobs and throughput", func(t *testing.T) {
+		var code []byte
+		for i := 0; i < 3; i++ {
+			require.NoError(t, repo.CreateSubmission(repo.ctx, userId, questionId, code, "go"))
+		}
+		leased, _, err := repo.LeaseSubmissions(repo.ctx, judge.Id, judge.Languages, 3)
+		require.NoError(t, err)
+		require.Len(t, leased, 3)
+
+		sId, err := strconv.Atoi(leased[0].GetId())
+		require.NoError(t, err)
+		state := proto.SubmissionState_SUBMISSION_STATE_OK
+		updated, err := repo.UpdateSubmissionResult(repo.ctx, int32(sId),
+			&proto.Submission{State: &state, Judge: &judge.Id})
+		require.NoError(t, err)
+		require.True(t, updated)
+
+		registered, err := repo.GetJudge(repo.ctx, judge.Id)
+		require.NoError(t, err)
+		require.Equal(t, int32(2), registered.CurrentJobs)
+		require.Equal(t, int32(1), registered.JudgedLastHour)
+	})
+
+	t.Run("judge offline without heartbeat", func(t *testing.T) {
+		_, err := repo.pool.Exec(repo.ctx, `UPDATE judges SET last_heartbeat_at = now() - interval '1 hour'`)
+		require.NoError(t, err)
+		registered, err := repo.GetJudge(repo.ctx, judge.Id)
+		require.NoError(t, err)
+		require.Equal(t, proto.JudgeStatus_JUDGE_STATUS_OFFLINE, registered.Status)
+
+		err = repo.RecordJudgeHeartbeat(repo.ctx, judge.Id)
+		require.NoError(t, err)
+		registered, err = repo.GetJudge(repo.ctx, judge.Id)
+		require.NoError(t, err)
+		require.Equal(t, proto.JudgeStatus_JUDGE_STATUS_ONLINE, registered.Status)
+
+		err = repo.RecordJudgeHeartbeat(repo.ctx, "unknown")
+		require.Equal(t, pgx.ErrNoRows, err)
+	})
+
+	t.Run("disable judge success", func(t *testing.T) {
+		err := repo.SetJudgeDisabled(repo.ctx, judge.Id, true)
+		require.NoError(t, err)
+		err = repo.RegisterJudge(repo.ctx, judge)
+		require.NoError(t, err)
+		registered, err := repo.GetJudge(repo.ctx, judge.Id)
+		require.NoError(t, err)
+		require.Equal(t, proto.JudgeStatus_JUDGE_STATUS_DISABLED, registered.Status)
+
+		err = repo.SetJudgeDisabled(repo.ctx, judge.Id, false)
+		require.NoError(t, err)
+		registered, err = repo.GetJudge(repo.ctx, judge.Id)
+		require.NoError(t, err)
+		require.Equal(t, proto.JudgeStatus_JUDGE_STATUS_ONLINE, registered.Status)
+	})
+
+	t.Run("disable judge fail, judge not found", func(t *testing.T) {
+		err := repo.SetJudgeDisabled(repo.ctx, "unknown", true)
+		require.Equal(t, pgx.ErrNoRows, err)
+	})
+
+	t.Run("judge languages of enabled judges", func(t *testing.T) {
+		other := &proto.Judge{Id: "judge-2", Hostname: "host-2", Languages: []string{"go", "python"}, Capacity: 1}
+		require.NoError(t, repo.RegisterJudge(repo.ctx, other))
+
+		languages, err := repo.GetJudgeLanguages(repo.ctx)
+		require.NoError(t, err)
+		require.Equal(t, []string{"c", "go", "python"}, languages)
+
+		require.NoError(t, repo.SetJudgeDisabled(repo.ctx, other.Id, true))
+		languages, err = repo.GetJudgeLanguages(repo.ctx)
+		require.NoError(t, err)
+		require.Equal(t, []string{"c", "go"}, languages)
+	})
+}
+
+func TestJudgeKey(t *testing.T) {
+	repo, err := newRepository(true)
+	require.NoError(t, err)
+
+	key, err := internal.GenerateAPIKey()
+	require.NoError(t, err)
+	keyHash := internal.HashAPIKey(key)
+	var keyId int
+
+	t.Run("create judge key success", func(t *testing.T) {
+		judgeKey, err := repo.CreateJudgeKey(repo.ctx, "judge-1", keyHash)
+		require.NoError(t, err)
+		require.Equal(t, "judge-1", judgeKey.JudgeId)
+		require.NotZero(t, judgeKey.CreatedAt)
+		keyId, err = strconv.Atoi(judgeKey.GetId())
+		require.NoError(t, err)
+	})
+
+	t.Run("get judge key owner success", func(t *testing.T) {
+		judgeId, err := repo.GetJudgeKeyOwner(repo.ctx, keyHash)
+		require.NoError(t, err)
+		require.Equal(t, "judge-1", judgeId)
+	})
+
+	t.Run("get judge key owner fail, wrong key", func(t *testing.T) {
+		_, err := repo.GetJudgeKeyOwner(repo.ctx, internal.HashAPIKey("wrong"))
+		require.Equal(t, pgx.ErrNoRows, err)
+	})
+
+	t.Run("revoke judge key success", func(t *testing.T) {
+		err := repo.RevokeJudgeKey(repo.ctx, int32(keyId))
+		require.NoError(t, err)
+
+		_, err = repo.GetJudgeKeyOwner(repo.ctx, keyHash)
+		require.Equal(t, pgx.ErrNoRows, err)
+
+		keys, err := repo.GetJudgeKeys(repo.ctx)
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		require.True(t, keys[0].Revoked)
+		require.Empty(t, keys[0].Key)
+	})
+
+	t.Run("revoke judge key fail, already revoked", func(t *testing.T) {
+		err := repo.RevokeJudgeKey(repo.ctx, int32(keyId))
+		require.Equal(t, pgx.ErrNoRows, err)
+	})
+}
+
+// replayMigration reverts and reapplies a migration inside tx, which the caller rolls back.
+func (p *postgresqlRepository) replayMigration(t *testing.T, tx pgx.Tx, name string) {
+	for _, direction := range []string{"down", "up"} {
+		migration, err := os.ReadFile(filepath.Join("migrations", name+"."+direction+".sql"))
+		require.NoError(t, err)
+		_, err = tx.Exec(p.ctx, string(migration))
+		require.NoError(t, err)
+	}
+}
+
+func TestSubtaskMigration(t *testing.T) {
+	repo, err := newRepository(true)
+	require.NoError(t, err)
+	err = repo.SetUp()
+	require.NoError(t, err)
+	userId, err := repo.CreateMember(repo.ctx, "username", "password")
+	require.NoError(t, err)
+	questionId, err := repo.CreateQuestion(repo.ctx, userId, &proto.Question{Title: "Test Question"})
+	require.NoError(t, err)
+
+	tx, err := repo.pool.Begin(repo.ctx)
+	require.NoError(t, err)
+	defer tx.Rollback(repo.ctx)
+
+	full, none := int32(100), int32(0)
+	scores := map[proto.SubmissionState]*int32{
+		proto.SubmissionState_SUBMISSION_STATE_PENDING:               nil,
+		proto.SubmissionState_SUBMISSION_STATE_JUDGING:               nil,
+		proto.SubmissionState_SUBMISSION_STATE_OK:                    &full,
+		proto.SubmissionState_SUBMISSION_STATE_COMPILE_ERROR:         &none,
+		proto.SubmissionState_SUBMISSION_STATE_WRONG_ANSWER:          &none,
+		proto.SubmissionState_SUBMISSION_STATE_MEMORY_LIMIT_EXCEEDED: &none,
+		proto.SubmissionState_SUBMISSION_STATE_TIME_LIMIT_EXCEEDED:   &none,
+		proto.SubmissionState_SUBMISSION_STATE_RUNTIME_ERROR:         &none,
+		proto.SubmissionState_SUBMISSION_STATE_FAILED:                nil,
+	}
+	submissions := make(map[int32]proto.SubmissionState)
+	for state := range scores {
+		var submissionId int32
+		err := tx.QueryRow(repo.ctx, createSubmissionQuery, userId, questionId, []byte("code"), state, "go").
+			Scan(&submissionId)
+		require.NoError(t, err)
+		submissions[submissionId] = state
+	}
+
+	repo.replayMigration(t, tx, "000010_subtasks")
+
+	for submissionId, state := range submissions {
+		var score, maxScore *int32
+		err := tx.QueryRow(repo.ctx, "SELECT score, max_score FROM submissions WHERE id = $1", submissionId).
+			Scan(&score, &maxScore)
+		require.NoError(t, err)
+		require.Equal(t, scores[state], score, state.String())
+		if scores[state] == nil {
+			require.Nil(t, maxScore, state.String())
+		} else {
+			require.Equal(t, full, *maxScore, state.String())
+		}
+	}
+}
+
+func TestQuestionInputModeMigration(t *testing.T) {
+	repo, err := newRepository(true)
+	require.NoError(t, err)
+
+	tx, err := repo.pool.Begin(repo.ctx)
+	require.NoError(t, err)
+	defer tx.Rollback(repo.ctx)
+
+	insertQuestion := "INSERT INTO questions (title) VALUES ($1) RETURNING id"
+	selectInputMode := "SELECT input_mode FROM questions WHERE id = $1"
+	var existingId int32
+	err = tx.QueryRow(repo.ctx, insertQuestion, "Existing Question").Scan(&existingId)
+	require.NoError(t, err)
+
+	repo.replayMigration(t, tx, "000003_question_input_mode")
+
+	t.Run("existing questions read arguments", func(t *testing.T) {
+		var inputMode proto.InputMode
+		err := tx.QueryRow(repo.ctx, selectInputMode, existingId).Scan(&inputMode)
+		require.NoError(t, err)
+		require.Equal(t, proto.InputMode_INPUT_MODE_ARGUMENTS, inputMode)
+	})
+
+	t.Run("new questions read stdin by default", func(t *testing.T) {
+		var newId int32
+		err := tx.QueryRow(repo.ctx, insertQuestion, "New Question").Scan(&newId)
+		require.NoError(t, err)
+		var inputMode proto.InputMode
+		err = tx.QueryRow(repo.ctx, selectInputMode, newId).Scan(&inputMode)
+		require.NoError(t, err)
+		require.Equal(t, proto.InputMode_INPUT_MODE_STDIN, inputMode)
+	})
 }
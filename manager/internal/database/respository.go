@@ -12,6 +12,7 @@ import (
 	"google.golang.org/grpc/status"
 	"log"
 	"math"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -30,7 +31,7 @@ type Repository interface {
 	GetUserRoleByUsername(ctx context.Context, username string) (int32, proto.Role, error)
 	UpdateUserRole(ctx context.Context, userId int32, role proto.Role) error
 	GetUsernames(ctx context.Context, pageNumber, pageSize int) ([]string, int, error)
-	GetUserStats(ctx context.Context, userId int32) (int64, int64, error)
+	GetUserStats(ctx context.Context, userId int32) (*proto.GetStatsResponse, error)
 	GetQuestions(ctx context.Context, publishedOnly bool, pageNumber, pageSize int) ([]*proto.Question, int, error)
 	GetUserQuestions(ctx context.Context, userId int32,
 		username string, pageNumber, pageSize int) ([]*proto.Question, int, error)
@@ -38,9 +39,35 @@ type Repository interface {
 	ChangeQuestionState(ctx context.Context, questionId int, state int32) error
 	CreateQuestion(ctx context.Context, owner int32, question *proto.Question) (int32, error)
 	EditQuestion(ctx context.Context, question *proto.Question) error
-	CreateSubmission(ctx context.Context, userId int32, questionId int32, code []byte) error
+	GetTestCases(ctx context.Context, questionId int) ([]*proto.TestCase, error)
+	GetTestCaseQuestion(ctx context.Context, testCaseId int) (int, error)
+	CreateTestCase(ctx context.Context, questionId int, testCase *proto.TestCase) (int32, error)
+	EditTestCase(ctx context.Context, testCaseId int, testCase *proto.TestCase) error
+	DeleteTestCase(ctx context.Context, testCaseId int) error
+	GetSubtasks(ctx context.Context, questionId int) ([]*proto.Subtask, error)
+	GetSubtaskQuestion(ctx context.Context, subtaskId int) (int, error)
+	CreateSubtask(ctx context.Context, questionId int, subtask *proto.Subtask) (int32, error)
+	EditSubtask(ctx context.Context, subtaskId int, subtask *proto.Subtask) error
+	DeleteSubtask(ctx context.Context, subtaskId int) error
+	CreateSubmission(ctx context.Context, userId int32, questionId int32, code []byte, language string) error
 	UpdateSubmissionState(ctx context.Context, submissionId int32, state int32) (bool, error)
+	UpdateSubmissionResult(ctx context.Context, submissionId int32, result *proto.Submission) (bool, error)
+	LeaseSubmissions(ctx context.Context, judge string, languages []string, count int) ([]*proto.Submission, time.Time, error)
+	RenewLeases(ctx context.Context, judge string, submissionIds []int32) ([]int32, time.Time, error)
+	RequeueExpiredLeases(ctx context.Context) (int, error)
+	RegisterJudge(ctx context.Context, judge *proto.Judge) error
+	GetJudges(ctx context.Context) ([]*proto.Judge, error)
+	GetJudgeLanguages(ctx context.Context) ([]string, error)
+	GetJudge(ctx context.Context, judgeId string) (*proto.Judge, error)
+	SetJudgeDisabled(ctx context.Context, judgeId string, disabled bool) error
+	RecordJudgeHeartbeat(ctx context.Context, judgeId string) error
+	CreateJudgeKey(ctx context.Context, judgeId string, keyHash string) (*proto.JudgeKey, error)
+	RevokeJudgeKey(ctx context.Context, keyId int32) error
+	GetJudgeKeys(ctx context.Context) ([]*proto.JudgeKey, error)
+	GetJudgeKeyOwner(ctx context.Context, keyHash string) (string, error)
+	GetSubmission(ctx context.Context, submissionId int32) (*proto.Submission, int32, error)
 	GetSubmissionsWithState(ctx context.Context, state int32, pageNumber, pageSize int) ([]*proto.Submission, int, error)
+	GetPendingSubmissionIds(ctx context.Context, limit int) ([]string, error)
 	GetUserSubmissions(ctx context.Context, userId int32,
 		questionId int32, filterQuestion bool, pageNumber, pageSize int) ([]*proto.Submission, int, error)
 }
@@ -229,10 +256,11 @@ func (p *postgresqlRepository) GetUsernames(ctx context.Context, pageNumber, pag
 	return usernames, totalPage, nil
 }
 
-func (p *postgresqlRepository) GetUserStats(ctx context.Context, userId int32) (int64, int64, error) {
-	var triedCount, successCount int64
-	err := p.pool.QueryRow(ctx, getUserStatsQuery, userId, int32(proto.SubmissionState_SUBMISSION_STATE_OK)).Scan(&triedCount, &successCount)
-	return triedCount, successCount, err
+func (p *postgresqlRepository) GetUserStats(ctx context.Context, userId int32) (*proto.GetStatsResponse, error) {
+	stats := &proto.GetStatsResponse{}
+	err := p.pool.QueryRow(ctx, getUserStatsQuery, userId, int32(proto.SubmissionState_SUBMISSION_STATE_OK)).Scan(
+		&stats.TriedQuestions, &stats.SolvedQuestions, &stats.Score, &stats.MaxScore)
+	return stats, err
 }
 
 func (p *postgresqlRepository) GetQuestions(ctx context.Context, publishedOnly bool, pageNumber, pageSize int) (
@@ -337,7 +365,9 @@ func (p *postgresqlRepository) GetQuestion(ctx context.Context, questionId int)
 	question.Limitations = limitations
 	err := p.pool.QueryRow(ctx, getQuestionQuery, questionId).Scan(&question.Id, &question.Title,
 		&question.Statement, &question.Input, &question.Output, &limitations.Memory, &limitations.Duration,
-		&question.State, &question.Owner)
+		&limitations.Output, &question.State, &question.Owner, &question.InputMode, &question.Checker, &question.CheckerLanguage,
+		&question.ComparisonMode, &question.AbsoluteEpsilon, &question.RelativeEpsilon, &question.Interactor,
+		&question.InteractorLanguage)
 	if err != nil {
 		return nil, err
 	}
@@ -367,9 +397,20 @@ func (p *postgresqlRepository) CreateQuestion(ctx context.Context, owner int32,
 	timeLimit := limitations.GetDuration()
 	memoryLimit := limitations.GetMemory()
 	state := proto.QuestionState_QUESTION_STATE_DRAFT
+	inputMode := question.GetInputMode()
+	if inputMode == proto.InputMode_INPUT_MODE_UNKNOWN {
+		inputMode = proto.InputMode_INPUT_MODE_STDIN
+	}
+	comparisonMode := question.GetComparisonMode()
+	if comparisonMode == proto.ComparisonMode_COMPARISON_MODE_UNKNOWN {
+		comparisonMode = proto.ComparisonMode_COMPARISON_MODE_EXACT
+	}
 
 	var questionId int32
-	args := []interface{}{title, statement, owner, input, output, memoryLimit, timeLimit, state}
+	args := []interface{}{title, statement, owner, input, output, memoryLimit, timeLimit, state, inputMode,
+		question.GetChecker(), question.GetCheckerLanguage(), comparisonMode, question.GetAbsoluteEpsilon(),
+		question.GetRelativeEpsilon(), question.GetInteractor(), question.GetInteractorLanguage(),
+		limitations.GetOutput()}
 
 	err := p.pool.QueryRow(ctx, createQuestionQuery, args...).Scan(&questionId)
 	return questionId, err
@@ -413,6 +454,55 @@ func (p *postgresqlRepository) EditQuestion(ctx context.Context, question *proto
 		args = append(args, memoryLimit)
 		argIdx++
 	}
+	if outputLimit := question.GetLimitations().GetOutput(); outputLimit != 0 {
+		setClauses = append(setClauses, fmt.Sprintf("output_limit = $%d", argIdx))
+		args = append(args, outputLimit)
+		argIdx++
+	}
+
+	if inputMode := question.GetInputMode(); inputMode != proto.InputMode_INPUT_MODE_UNKNOWN {
+		setClauses = append(setClauses, fmt.Sprintf("input_mode = $%d", argIdx))
+		args = append(args, inputMode)
+		argIdx++
+	}
+
+	if checker := question.GetChecker(); len(checker) != 0 {
+		setClauses = append(setClauses, fmt.Sprintf("checker = $%d", argIdx))
+		args = append(args, checker)
+		argIdx++
+	}
+	if checkerLanguage := question.GetCheckerLanguage(); checkerLanguage != "" {
+		setClauses = append(setClauses, fmt.Sprintf("checker_language = $%d", argIdx))
+		args = append(args, checkerLanguage)
+		argIdx++
+	}
+
+	if interactor := question.GetInteractor(); len(interactor) != 0 {
+		setClauses = append(setClauses, fmt.Sprintf("interactor = $%d", argIdx))
+		args = append(args, interactor)
+		argIdx++
+	}
+	if interactorLanguage := question.GetInteractorLanguage(); interactorLanguage != "" {
+		setClauses = append(setClauses, fmt.Sprintf("interactor_language = $%d", argIdx))
+		args = append(args, interactorLanguage)
+		argIdx++
+	}
+
+	if comparisonMode := question.GetComparisonMode(); comparisonMode != proto.ComparisonMode_COMPARISON_MODE_UNKNOWN {
+		setClauses = append(setClauses, fmt.Sprintf("comparison_mode = $%d", argIdx))
+		args = append(args, comparisonMode)
+		argIdx++
+	}
+	if absoluteEpsilon := question.GetAbsoluteEpsilon(); absoluteEpsilon != 0 {
+		setClauses = append(setClauses, fmt.Sprintf("absolute_epsilon = $%d", argIdx))
+		args = append(args, absoluteEpsilon)
+		argIdx++
+	}
+	if relativeEpsilon := question.GetRelativeEpsilon(); relativeEpsilon != 0 {
+		setClauses = append(setClauses, fmt.Sprintf("relative_epsilon = $%d", argIdx))
+		args = append(args, relativeEpsilon)
+		argIdx++
+	}
 
 	if len(setClauses) == 0 {
 		return nil
@@ -432,16 +522,173 @@ func (p *postgresqlRepository) EditQuestion(ctx context.Context, question *proto
 	return nil
 }
 
+func (p *postgresqlRepository) GetTestCases(ctx context.Context, questionId int) ([]*proto.TestCase, error) {
+	rows, err := p.pool.Query(ctx, getTestCasesQuery, questionId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	testCases := []*proto.TestCase{}
+	for rows.Next() {
+		testCase := proto.TestCase{}
+		var input, output *string
+		err := rows.Scan(&testCase.Id, &testCase.QuestionId, &input, &output, &testCase.SubtaskId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		if input != nil {
+			testCase.Input = *input
+		}
+		if output != nil {
+			testCase.Output = *output
+		}
+		testCases = append(testCases, &testCase)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %v", err)
+	}
+	return testCases, nil
+}
+
+func (p *postgresqlRepository) GetTestCaseQuestion(ctx context.Context, testCaseId int) (int, error) {
+	var questionId int
+	err := p.pool.QueryRow(ctx, getTestCaseQuestionQuery, testCaseId).Scan(&questionId)
+	return questionId, err
+}
+
+func (p *postgresqlRepository) CreateTestCase(ctx context.Context, questionId int, testCase *proto.TestCase) (int32, error) {
+	subtaskId, err := optionalId(testCase.SubtaskId)
+	if err != nil {
+		return 0, err
+	}
+	var testCaseId int32
+	err = p.pool.QueryRow(ctx, createTestCaseQuery, questionId, testCase.GetInput(), testCase.GetOutput(),
+		subtaskId).Scan(&testCaseId)
+	return testCaseId, err
+}
+
+func (p *postgresqlRepository) EditTestCase(ctx context.Context, testCaseId int, testCase *proto.TestCase) error {
+	subtaskId, err := optionalId(testCase.SubtaskId)
+	if err != nil {
+		return err
+	}
+	cmdTag, err := p.pool.Exec(ctx, updateTestCaseQuery, testCaseId, testCase.GetInput(), testCase.GetOutput(),
+		subtaskId)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (p *postgresqlRepository) DeleteTestCase(ctx context.Context, testCaseId int) error {
+	cmdTag, err := p.pool.Exec(ctx, deleteTestCaseQuery, testCaseId)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (p *postgresqlRepository) GetSubtasks(ctx context.Context, questionId int) ([]*proto.Subtask, error) {
+	rows, err := p.pool.Query(ctx, getSubtasksQuery, questionId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	subtasks := []*proto.Subtask{}
+	for rows.Next() {
+		subtask := proto.Subtask{}
+		err := rows.Scan(&subtask.Id, &subtask.QuestionId, &subtask.Points, &subtask.ScoringMode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		subtasks = append(subtasks, &subtask)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %v", err)
+	}
+	return subtasks, nil
+}
+
+func (p *postgresqlRepository) GetSubtaskQuestion(ctx context.Context, subtaskId int) (int, error) {
+	var questionId int
+	err := p.pool.QueryRow(ctx, getSubtaskQuestionQuery, subtaskId).Scan(&questionId)
+	return questionId, err
+}
+
+func (p *postgresqlRepository) CreateSubtask(ctx context.Context, questionId int, subtask *proto.Subtask) (int32, error) {
+	scoringMode := subtask.GetScoringMode()
+	if scoringMode == proto.ScoringMode_SCORING_MODE_UNKNOWN {
+		scoringMode = proto.ScoringMode_SCORING_MODE_ALL_OR_NOTHING
+	}
+	var subtaskId int32
+	err := p.pool.QueryRow(ctx, createSubtaskQuery, questionId, subtask.GetPoints(), scoringMode).Scan(&subtaskId)
+	return subtaskId, err
+}
+
+func (p *postgresqlRepository) EditSubtask(ctx context.Context, subtaskId int, subtask *proto.Subtask) error {
+	scoringMode := subtask.GetScoringMode()
+	if scoringMode == proto.ScoringMode_SCORING_MODE_UNKNOWN {
+		scoringMode = proto.ScoringMode_SCORING_MODE_ALL_OR_NOTHING
+	}
+	cmdTag, err := p.pool.Exec(ctx, updateSubtaskQuery, subtaskId, subtask.GetPoints(), scoringMode)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (p *postgresqlRepository) DeleteSubtask(ctx context.Context, subtaskId int) error {
+	cmdTag, err := p.pool.Exec(ctx, deleteSubtaskQuery, subtaskId)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// optionalId converts an optional id received as a string to a nullable integer column value.
+func optionalId(id *string) (*int32, error) {
+	if id == nil {
+		return nil, nil
+	}
+	value, err := strconv.Atoi(*id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id %q: %v", *id, err)
+	}
+	result := int32(value)
+	return &result, nil
+}
+
 func (p *postgresqlRepository) CreateSubmission(ctx context.Context, userId int32,
-	questionId int32, code []byte) error {
+	questionId int32, code []byte, language string) error {
 	var submissionId int32
 	state := proto.SubmissionState_SUBMISSION_STATE_PENDING
-	err := p.pool.QueryRow(ctx, createSubmissionQuery, userId, questionId, code, state).Scan(&submissionId)
+	err := p.pool.QueryRow(ctx, createSubmissionQuery, userId, questionId, code, state, language).Scan(&submissionId)
 	return err
 }
 
 func (p *postgresqlRepository) UpdateSubmissionState(ctx context.Context, submissionId int32,
 	state int32) (bool, error) {
+	newState := proto.SubmissionState(state)
+	return p.UpdateSubmissionResult(ctx, submissionId, &proto.Submission{State: &newState})
+}
+
+func (p *postgresqlRepository) UpdateSubmissionResult(ctx context.Context, submissionId int32,
+	result *proto.Submission) (bool, error) {
+	state := int32(result.GetState())
 	tx, err := p.pool.Begin(ctx)
 	defer tx.Rollback(ctx)
 	if err != nil {
@@ -449,15 +696,21 @@ func (p *postgresqlRepository) UpdateSubmissionState(ctx context.Context, submis
 	}
 	sub := &submission{}
 	err = tx.QueryRow(ctx, selectSubmissionForUpdateQuery, submissionId).Scan(&sub.id, &sub.state, &sub.retryCount,
-		&sub.stateUpdatedAt)
+		&sub.stateUpdatedAt, &sub.judge)
 	if err != nil {
 		return false, err
 	}
 	if state == sub.state {
 		return false, nil
 	}
+	if result.Judge != nil && !sub.leasedTo(result.GetJudge()) {
+		// the lease expired and the submission was requeued, possibly to another judge
+		return false, nil
+	}
 
-	cmdTag, err := tx.Exec(ctx, updateSubmissionStateQuery, submissionId, state, sub.retryCount)
+	cmdTag, err := tx.Exec(ctx, updateSubmissionResultQuery, submissionId, state, sub.retryCount, result.FailedTest,
+		result.ExitCode, result.Signal, result.WallTime, result.CpuTime, result.Memory, result.CompilerOutput,
+		result.Score, result.MaxScore, result.CompileTime)
 	if err != nil {
 		return false, err
 	}
@@ -468,51 +721,98 @@ func (p *postgresqlRepository) UpdateSubmissionState(ctx context.Context, submis
 	if err := tx.Commit(ctx); err != nil {
 		return false, err
 	}
-	if state == int32(proto.SubmissionState_SUBMISSION_STATE_JUDGING) {
-		go func() {
-			if err := p.handleSubmissionJudgingTimeout(p.ctx, JudgeTimeout, submissionId); err != nil {
-				log.Printf("timeout handling failed for submission %d: %v", submissionId, err)
-			}
-		}()
-	}
-
 	return true, nil
 }
 
-func (p *postgresqlRepository) handleSubmissionJudgingTimeout(ctx context.Context, timeoutSeconds int, submissionId int32) error {
-	select {
-	case <-ctx.Done():
-		return nil
-	case <-time.After(time.Duration(timeoutSeconds) * time.Second):
-		tx, err := p.pool.Begin(ctx)
+// LeaseSubmissions moves up to count pending submissions the judge can judge in languages to judging
+// for the judge, and returns them with the expiry of their lease.
+func (p *postgresqlRepository) LeaseSubmissions(ctx context.Context, judge string, languages []string, count int) (
+	[]*proto.Submission, time.Time, error) {
+	rows, err := p.pool.Query(ctx, leaseSubmissionsQuery, proto.SubmissionState_SUBMISSION_STATE_PENDING,
+		proto.SubmissionState_SUBMISSION_STATE_JUDGING, judge, LeaseDuration, count, languages)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to execute query: %v", err)
+	}
+	var submissions []*proto.Submission
+	var leaseExpiresAt time.Time
+	for rows.Next() {
+		submission := proto.Submission{}
+		err := rows.Scan(&submission.Id, &submission.Code, &submission.QuestionId, &submission.State,
+			&submission.Language, &submission.Judge, &leaseExpiresAt)
 		if err != nil {
-			return err
+			return nil, time.Time{}, fmt.Errorf("failed to scan row: %v", err)
 		}
-		defer tx.Rollback(ctx)
-		sub := &submission{}
+		submissions = append(submissions, &submission)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, time.Time{}, fmt.Errorf("rows iteration error: %v", err)
+	}
+	return submissions, leaseExpiresAt, nil
+}
 
-		err = tx.QueryRow(ctx, selectSubmissionForUpdateQuery, submissionId).Scan(
-			&sub.id, &sub.state, &sub.retryCount, &sub.stateUpdatedAt)
-		if err != nil {
-			return err
+// RenewLeases extends the leases the judge holds on the given submissions, and returns the ids of the
+// renewed ones with their new expiry. Submissions missing from the result were requeued or finished.
+func (p *postgresqlRepository) RenewLeases(ctx context.Context, judge string, submissionIds []int32) (
+	[]int32, time.Time, error) {
+	rows, err := p.pool.Query(ctx, renewLeasesQuery, proto.SubmissionState_SUBMISSION_STATE_JUDGING, judge,
+		submissionIds, LeaseDuration)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to execute query: %v", err)
+	}
+	var renewed []int32
+	var leaseExpiresAt time.Time
+	for rows.Next() {
+		var submissionId int32
+		if err := rows.Scan(&submissionId, &leaseExpiresAt); err != nil {
+			return nil, time.Time{}, fmt.Errorf("failed to scan row: %v", err)
 		}
-		if sub.stateUpdatedAt.After(time.Now().Add(-time.Duration(timeoutSeconds) * time.Second)) {
-			return nil
+		renewed = append(renewed, submissionId)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, time.Time{}, fmt.Errorf("rows iteration error: %v", err)
+	}
+	return renewed, leaseExpiresAt, nil
+}
+
+// RequeueExpiredLeases moves judging submissions whose lease expired back to pending, or to failed once
+// they were tried MaxJudgeTryCount times, and returns how many became pending again.
+func (p *postgresqlRepository) RequeueExpiredLeases(ctx context.Context) (int, error) {
+	rows, err := p.pool.Query(ctx, requeueExpiredLeasesQuery, proto.SubmissionState_SUBMISSION_STATE_JUDGING,
+		proto.SubmissionState_SUBMISSION_STATE_PENDING, proto.SubmissionState_SUBMISSION_STATE_FAILED,
+		MaxJudgeTryCount, JudgeTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute query: %v", err)
+	}
+	requeued := 0
+	for rows.Next() {
+		var submissionId, state int32
+		if err := rows.Scan(&submissionId, &state); err != nil {
+			return 0, fmt.Errorf("failed to scan row: %v", err)
 		}
-		if sub.state == int32(proto.SubmissionState_SUBMISSION_STATE_JUDGING) {
-			sub.retryCount++
-			newState := proto.SubmissionState_SUBMISSION_STATE_PENDING
-			if sub.retryCount >= MaxJudgeTryCount {
-				newState = proto.SubmissionState_SUBMISSION_STATE_FAILED
-			}
-			_, err := tx.Exec(ctx, updateSubmissionStateQuery, submissionId, newState, sub.retryCount)
-			if err != nil {
-				return err
-			}
+		if state == int32(proto.SubmissionState_SUBMISSION_STATE_PENDING) {
+			requeued++
+		} else {
+			log.Printf("submission %d failed after %d judging attempts", submissionId, MaxJudgeTryCount)
 		}
-		err = tx.Commit(ctx)
-		return err
 	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("rows iteration error: %v", err)
+	}
+	return requeued, nil
+}
+
+// GetSubmission returns the submission and the id of the user who sent it.
+func (p *postgresqlRepository) GetSubmission(ctx context.Context, submissionId int32) (*proto.Submission, int32, error) {
+	submission := &proto.Submission{}
+	var userId int32
+	err := p.pool.QueryRow(ctx, getSubmissionQuery, submissionId).Scan(&submission.Id, &submission.Code,
+		&submission.QuestionId, &submission.State, &submission.FailedTest, &submission.Language,
+		&submission.ExitCode, &submission.Signal, &submission.WallTime, &submission.CpuTime, &submission.Memory,
+		&submission.CompileTime, &submission.Score, &submission.MaxScore, &submission.CompilerOutput, &submission.Judge, &userId)
+	if err != nil {
+		return nil, 0, err
+	}
+	return submission, userId, nil
 }
 
 func (p *postgresqlRepository) GetSubmissionsWithState(ctx context.Context, state int32, pageNumber, pageSize int) (
@@ -544,7 +844,10 @@ func (p *postgresqlRepository) GetSubmissionsWithState(ctx context.Context, stat
 	}
 	for rows.Next() {
 		submission := proto.Submission{}
-		err := rows.Scan(&submission.Id, &submission.Code, &submission.QuestionId, &submission.State)
+		err := rows.Scan(&submission.Id, &submission.Code, &submission.QuestionId, &submission.State,
+			&submission.FailedTest, &submission.Language, &submission.ExitCode, &submission.Signal,
+			&submission.WallTime, &submission.CpuTime, &submission.Memory, &submission.CompileTime, &submission.Score,
+			&submission.MaxScore)
 		if err != nil {
 			return nil, totalPage, fmt.Errorf("failed to scan row: %v", err)
 		}
@@ -556,6 +859,26 @@ func (p *postgresqlRepository) GetSubmissionsWithState(ctx context.Context, stat
 	return submissions, totalPage, nil
 }
 
+// GetPendingSubmissionIds returns the ids of up to limit pending submissions, oldest first.
+func (p *postgresqlRepository) GetPendingSubmissionIds(ctx context.Context, limit int) ([]string, error) {
+	rows, err := p.pool.Query(ctx, getPendingSubmissionIdsQuery, proto.SubmissionState_SUBMISSION_STATE_PENDING, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %v", err)
+	}
+	return ids, nil
+}
+
 func (p *postgresqlRepository) GetUserSubmissions(ctx context.Context, userId int32,
 	questionId int32, filterQuestion bool, pageNumber, pageSize int) ([]*proto.Submission, int, error) {
 
@@ -599,7 +922,10 @@ func (p *postgresqlRepository) GetUserSubmissions(ctx context.Context, userId in
 	}
 	for rows.Next() {
 		submission := proto.Submission{}
-		err := rows.Scan(&submission.Id, &submission.Code, &submission.QuestionId, &submission.State)
+		err := rows.Scan(&submission.Id, &submission.Code, &submission.QuestionId, &submission.State,
+			&submission.FailedTest, &submission.Language, &submission.ExitCode, &submission.Signal,
+			&submission.WallTime, &submission.CpuTime, &submission.Memory, &submission.CompileTime, &submission.Score,
+			&submission.MaxScore)
 		if err != nil {
 			return nil, totalPage, fmt.Errorf("failed to scan row: %v", err)
 		}
@@ -610,3 +936,162 @@ func (p *postgresqlRepository) GetUserSubmissions(ctx context.Context, userId in
 	}
 	return submissions, totalPage, nil
 }
+
+// RegisterJudge creates the judge or updates what it reported, keeping whether an admin disabled it.
+func (p *postgresqlRepository) RegisterJudge(ctx context.Context, judge *proto.Judge) error {
+	languages := judge.GetLanguages()
+	if languages == nil {
+		languages = []string{}
+	}
+	_, err := p.pool.Exec(ctx, registerJudgeQuery, judge.GetId(), judge.GetHostname(), languages,
+		judge.GetCapacity())
+	return err
+}
+
+func (p *postgresqlRepository) GetJudges(ctx context.Context) ([]*proto.Judge, error) {
+	return p.queryJudges(ctx, "")
+}
+
+func (p *postgresqlRepository) GetJudge(ctx context.Context, judgeId string) (*proto.Judge, error) {
+	judges, err := p.queryJudges(ctx, judgeId)
+	if err != nil {
+		return nil, err
+	}
+	if len(judges) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return judges[0], nil
+}
+
+// GetJudgeLanguages returns the languages registered by the judges that are not disabled, in order.
+func (p *postgresqlRepository) GetJudgeLanguages(ctx context.Context) ([]string, error) {
+	rows, err := p.pool.Query(ctx, getJudgeLanguagesQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	var languages []string
+	for rows.Next() {
+		var language string
+		if err := rows.Scan(&language); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		languages = append(languages, language)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %v", err)
+	}
+	return languages, nil
+}
+
+// queryJudges returns every judge when judgeId is empty, and the judge with that id otherwise.
+func (p *postgresqlRepository) queryJudges(ctx context.Context, judgeId string) ([]*proto.Judge, error) {
+	rows, err := p.pool.Query(ctx, getJudgesQuery, proto.SubmissionState_SUBMISSION_STATE_JUDGING,
+		proto.SubmissionState_SUBMISSION_STATE_PENDING, judgeId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	var judges []*proto.Judge
+	for rows.Next() {
+		judge := proto.Judge{}
+		var disabled bool
+		var lastHeartbeat *time.Time
+		err := rows.Scan(&judge.Id, &judge.Hostname, &judge.Languages, &judge.Capacity, &disabled, &lastHeartbeat,
+			&judge.CurrentJobs, &judge.JudgedLastHour)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		judge.Status = judgeStatus(disabled, lastHeartbeat)
+		if lastHeartbeat != nil {
+			judge.LastHeartbeat = lastHeartbeat.UnixMilli()
+		}
+		judges = append(judges, &judge)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %v", err)
+	}
+	return judges, nil
+}
+
+func judgeStatus(disabled bool, lastHeartbeat *time.Time) proto.JudgeStatus {
+	if disabled {
+		return proto.JudgeStatus_JUDGE_STATUS_DISABLED
+	}
+	if lastHeartbeat == nil || time.Since(*lastHeartbeat) > JudgeOfflineAfter*time.Second {
+		return proto.JudgeStatus_JUDGE_STATUS_OFFLINE
+	}
+	return proto.JudgeStatus_JUDGE_STATUS_ONLINE
+}
+
+func (p *postgresqlRepository) SetJudgeDisabled(ctx context.Context, judgeId string, disabled bool) error {
+	cmdTag, err := p.pool.Exec(ctx, updateJudgeDisabledQuery, judgeId, disabled)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (p *postgresqlRepository) RecordJudgeHeartbeat(ctx context.Context, judgeId string) error {
+	cmdTag, err := p.pool.Exec(ctx, updateJudgeHeartbeatQuery, judgeId)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (p *postgresqlRepository) CreateJudgeKey(ctx context.Context, judgeId string, keyHash string) (
+	*proto.JudgeKey, error) {
+	key := &proto.JudgeKey{JudgeId: judgeId}
+	var createdAt time.Time
+	err := p.pool.QueryRow(ctx, createJudgeKeyQuery, judgeId, keyHash).Scan(&key.Id, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	key.CreatedAt = createdAt.UnixMilli()
+	return key, nil
+}
+
+// RevokeJudgeKey returns pgx.ErrNoRows when the key does not exist or is already revoked.
+func (p *postgresqlRepository) RevokeJudgeKey(ctx context.Context, keyId int32) error {
+	cmdTag, err := p.pool.Exec(ctx, revokeJudgeKeyQuery, keyId)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (p *postgresqlRepository) GetJudgeKeys(ctx context.Context) ([]*proto.JudgeKey, error) {
+	rows, err := p.pool.Query(ctx, getJudgeKeysQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %v", err)
+	}
+	var keys []*proto.JudgeKey
+	for rows.Next() {
+		key := proto.JudgeKey{}
+		var createdAt time.Time
+		if err := rows.Scan(&key.Id, &key.JudgeId, &createdAt, &key.Revoked); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		key.CreatedAt = createdAt.UnixMilli()
+		keys = append(keys, &key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %v", err)
+	}
+	return keys, nil
+}
+
+// GetJudgeKeyOwner returns the id of the judge a key that is not revoked was issued to.
+func (p *postgresqlRepository) GetJudgeKeyOwner(ctx context.Context, keyHash string) (string, error) {
+	var judgeId string
+	err := p.pool.QueryRow(ctx, getJudgeKeyOwnerQuery, keyHash).Scan(&judgeId)
+	return judgeId, err
+}
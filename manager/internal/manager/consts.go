@@ -1,5 +1,7 @@
 package manager
 
+import "time"
+
 const (
 	pageNumberName      = "page"
 	defaultPageNumber   = 1
@@ -11,3 +13,21 @@ const (
 	usernameMinLength   = 4
 	passwordMinLength   = 8
 )
+
+const (
+	jobsBatchSize      = 100 // pending submissions looked at on every wake-up of a job stream
+	jobsResyncInterval = 5 * time.Second
+	leaseSweepInterval = 5 * time.Second
+)
+
+const defaultLanguage = "go"
+
+// languageNames are the display names of the languages of the default judge config, other languages
+// judges register are shown by their id.
+var languageNames = map[string]string{
+	"go":     "Go",
+	"c":      "C",
+	"cpp":    "C++",
+	"python": "Python 3",
+	"java":   "Java",
+}
@@ -0,0 +1,90 @@
+package manager
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/CT1403-2/Code-Judgement/proto"
+)
+
+// jobNotifier wakes up the job streams of connected judges when a submission may have become pending.
+type jobNotifier struct {
+	mu      sync.Mutex
+	streams map[chan struct{}]struct{}
+}
+
+func newJobNotifier() *jobNotifier {
+	return &jobNotifier{streams: make(map[chan struct{}]struct{})}
+}
+
+func (n *jobNotifier) subscribe() chan struct{} {
+	wakeup := make(chan struct{}, 1)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.streams[wakeup] = struct{}{}
+	return wakeup
+}
+
+func (n *jobNotifier) unsubscribe(wakeup chan struct{}) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.streams, wakeup)
+}
+
+// notify never blocks, a stream that has not consumed the previous wake-up is already due to look again.
+func (n *jobNotifier) notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for wakeup := range n.streams {
+		select {
+		case wakeup <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// sendPendingSubmissions sends the ids of the pending submissions that were not sent on the stream yet and
+// returns the ids of the ones still pending, so a submission requeued after leaving the pending state is sent
+// again. Judges lease what they judge, the ids only wake them up.
+func (m *Manager) sendPendingSubmissions(ctx context.Context, stream proto.Manager_StreamJobsServer,
+	sent map[string]bool) (map[string]bool, error) {
+	ids, err := m.db.GetPendingSubmissionIds(ctx, jobsBatchSize)
+	if err != nil {
+		return sent, getCodeOrInternalError(err)
+	}
+	pending := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		pending[id] = true
+		if sent[id] {
+			continue
+		}
+		if err := stream.Send(&proto.Submission{Id: &id}); err != nil {
+			return pending, err
+		}
+	}
+	return pending, nil
+}
+
+// SweepExpiredLeases requeues the submissions of judges that stopped sending heartbeats until ctx is done.
+// The leases live in the database, so submissions left judging while the manager was down are requeued
+// on the first sweep.
+func (m *Manager) SweepExpiredLeases(ctx context.Context) {
+	ticker := time.NewTicker(leaseSweepInterval)
+	defer ticker.Stop()
+	for {
+		requeued, err := m.db.RequeueExpiredLeases(ctx)
+		if err != nil {
+			log.Printf("failed to requeue expired leases: %v", err)
+		} else if requeued > 0 {
+			log.Printf("requeued %d submissions with expired leases", requeued)
+			m.jobs.notify()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
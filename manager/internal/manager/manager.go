@@ -9,18 +9,27 @@ import (
 	"github.com/CT1403-2/Code-Judgement/proto"
 	"github.com/jackc/pgx/v5"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"slices"
 	"strconv"
+	"time"
 )
 
 type Manager struct {
-	db database.Repository
+	db   database.Repository
+	jobs *jobNotifier
+	// requireJudgeCertificates refuses judge API keys on connections without a verified client certificate
+	requireJudgeCertificates bool
 	proto.UnimplementedManagerServer
 }
 
-func NewManager() (*Manager, error) {
+// NewManager returns a manager, which accepts judge API keys only along with a verified client
+// certificate when requireJudgeCertificates is set.
+func NewManager(requireJudgeCertificates bool) (*Manager, error) {
 	db, err := database.NewRepository()
-	return &Manager{db: db}, err
+	return &Manager{db: db, jobs: newJobNotifier(), requireJudgeCertificates: requireJudgeCertificates}, err
 }
 
 func (m *Manager) Register(ctx context.Context, authRequest *proto.AuthenticationRequest) (*proto.AuthenticationResponse, error) {
@@ -64,7 +73,7 @@ func (m *Manager) Login(ctx context.Context, authRequest *proto.AuthenticationRe
 }
 
 func (m *Manager) ChangeRole(ctx context.Context, req *proto.ChangeRoleRequest) (*proto.Empty, error) {
-	userId, _, err := authenticate(ctx)
+	userId, _, err := m.authenticate(ctx)
 	if err != nil {
 		return &proto.Empty{}, status.Error(codes.Unauthenticated, err.Error())
 	}
@@ -113,7 +122,7 @@ func (m *Manager) ChangeRole(ctx context.Context, req *proto.ChangeRoleRequest)
 func (m *Manager) GetProfile(ctx context.Context, req *proto.ID) (*proto.GetProfileResponse, error) {
 	username := req.GetValue()
 	if username == "" { //return client username and role
-		userId, _, err := authenticate(ctx)
+		userId, _, err := m.authenticate(ctx)
 		if err != nil {
 			return nil, status.Error(codes.Unauthenticated, err.Error())
 		}
@@ -162,14 +171,14 @@ func (m *Manager) GetStatsRequest(ctx context.Context, req *proto.ID) (*proto.Ge
 		}
 		return &proto.GetStatsResponse{}, getCodeOrInternalError(err)
 	}
-	triedCount, successCount, err := m.db.GetUserStats(ctx, userId)
+	stats, err := m.db.GetUserStats(ctx, userId)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return &proto.GetStatsResponse{}, status.Error(codes.NotFound, "user not found")
 		}
 		return &proto.GetStatsResponse{}, getCodeOrInternalError(err)
 	}
-	return &proto.GetStatsResponse{TriedQuestions: triedCount, SolvedQuestions: successCount}, nil
+	return stats, nil
 }
 
 func (m *Manager) GetQuestions(ctx context.Context, req *proto.GetQuestionsRequest) (*proto.GetQuestionsResponse, error) {
@@ -178,7 +187,7 @@ func (m *Manager) GetQuestions(ctx context.Context, req *proto.GetQuestionsReque
 	var isOwner, publishedOnly bool
 	var questions []*proto.Question
 
-	userId, _, err := authenticate(ctx)
+	userId, _, err := m.authenticate(ctx)
 	if err != nil {
 		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
@@ -213,7 +222,7 @@ func (m *Manager) GetQuestions(ctx context.Context, req *proto.GetQuestionsReque
 }
 
 func (m *Manager) GetQuestion(ctx context.Context, req *proto.ID) (*proto.GetQuestionResponse, error) {
-	userId, isJudge, err := authenticate(ctx)
+	userId, isJudge, err := m.authenticate(ctx)
 	if err != nil {
 		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
@@ -237,16 +246,29 @@ func (m *Manager) GetQuestion(ctx context.Context, req *proto.ID) (*proto.GetQue
 		}
 		return nil, getCodeOrInternalError(err)
 	}
+	question.Subtasks, err = m.db.GetSubtasks(ctx, questionId)
+	if err != nil {
+		return nil, getCodeOrInternalError(err)
+	}
 
 	if !isJudge && !isAdmin(role) && username != question.GetOwner() {
 		question.Input = nil
 		question.Output = nil
+		question.Checker = nil
+		question.CheckerLanguage = ""
+		question.Interactor = nil
+		question.InteractorLanguage = ""
+	} else {
+		question.TestCases, err = m.db.GetTestCases(ctx, questionId)
+		if err != nil {
+			return nil, getCodeOrInternalError(err)
+		}
 	}
 	return &proto.GetQuestionResponse{Question: question}, status.Error(codes.OK, "")
 }
 
 func (m *Manager) Submit(ctx context.Context, req *proto.SubmitRequest) (*proto.Empty, error) {
-	userId, _, err := authenticate(ctx)
+	userId, _, err := m.authenticate(ctx)
 	if err != nil {
 		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
@@ -267,17 +289,43 @@ func (m *Manager) Submit(ctx context.Context, req *proto.SubmitRequest) (*proto.
 		return &proto.Empty{}, status.Error(codes.PermissionDenied, "question is not published")
 	}
 
+	language := submission.GetLanguage()
+	if language == "" {
+		language = defaultLanguage
+	}
+	if err := m.checkSupportedLanguage(ctx, "language", language); err != nil {
+		return &proto.Empty{}, err
+	}
+
 	code := submission.GetCode()
 
-	err = m.db.CreateSubmission(ctx, userId, int32(questionId), code)
+	err = m.db.CreateSubmission(ctx, userId, int32(questionId), code, language)
 	if err != nil {
 		return nil, getCodeOrInternalError(err)
 	}
+	m.jobs.notify()
 	return &proto.Empty{}, status.Error(codes.OK, "")
 }
 
+// GetLanguages returns the languages judges that are not disabled registered.
+func (m *Manager) GetLanguages(ctx context.Context, req *proto.Empty) (*proto.GetLanguagesResponse, error) {
+	ids, err := m.db.GetJudgeLanguages(ctx)
+	if err != nil {
+		return nil, getCodeOrInternalError(err)
+	}
+	languages := make([]*proto.Language, 0, len(ids))
+	for _, id := range ids {
+		name, ok := languageNames[id]
+		if !ok {
+			name = id
+		}
+		languages = append(languages, &proto.Language{Id: id, Name: name})
+	}
+	return &proto.GetLanguagesResponse{Languages: languages}, nil
+}
+
 func (m *Manager) GetSubmissions(ctx context.Context, req *proto.GetSubmissionsRequest) (*proto.GetSubmissionsResponse, error) {
-	userId, isJudge, err := authenticate(ctx)
+	userId, isJudge, err := m.authenticate(ctx)
 	if err != nil {
 		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
@@ -334,11 +382,48 @@ func (m *Manager) GetSubmissions(ctx context.Context, req *proto.GetSubmissionsR
 	}
 }
 
+func (m *Manager) GetSubmission(ctx context.Context, req *proto.ID) (*proto.GetSubmissionResponse, error) {
+	userId, isJudge, err := m.authenticate(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if isJudge {
+		return nil, status.Error(codes.PermissionDenied, "judges can not see submission details")
+	}
+
+	submissionId, err := strconv.Atoi(req.Value)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "submission not found: %v", req.Value)
+	}
+	submission, submitter, err := m.db.GetSubmission(ctx, int32(submissionId))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "submission not found")
+		}
+		return nil, getCodeOrInternalError(err)
+	}
+
+	_, role, err := m.db.GetUserRole(ctx, userId)
+	if err != nil {
+		return nil, getCodeOrInternalError(err)
+	}
+	if !isAdmin(role) {
+		if submitter != userId {
+			return nil, status.Error(codes.PermissionDenied, "only the submitter and admins can see this submission")
+		}
+		submission.Judge = nil
+	}
+	return &proto.GetSubmissionResponse{Submission: submission}, status.Error(codes.OK, "")
+}
+
 func (m *Manager) CreateQuestion(ctx context.Context, question *proto.Question) (*proto.ID, error) {
-	userId, _, err := authenticate(ctx)
+	userId, _, err := m.authenticate(ctx)
 	if err != nil {
 		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
+	if err := m.validateQuestion(ctx, question); err != nil {
+		return nil, err
+	}
 	questionId, err := m.db.CreateQuestion(ctx, userId, question)
 	if err != nil {
 		return nil, getCodeOrInternalError(err)
@@ -348,7 +433,7 @@ func (m *Manager) CreateQuestion(ctx context.Context, question *proto.Question)
 }
 
 func (m *Manager) EditQuestion(ctx context.Context, question *proto.Question) (*proto.Empty, error) {
-	userId, _, err := authenticate(ctx)
+	userId, _, err := m.authenticate(ctx)
 	if err != nil {
 		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
@@ -373,6 +458,9 @@ func (m *Manager) EditQuestion(ctx context.Context, question *proto.Question) (*
 	if q.GetOwner() != username {
 		return nil, status.Error(codes.PermissionDenied, "you do not have access to this question")
 	}
+	if err := m.validateQuestion(ctx, question); err != nil {
+		return nil, err
+	}
 
 	err = m.db.EditQuestion(ctx, question)
 	if err != nil {
@@ -382,7 +470,7 @@ func (m *Manager) EditQuestion(ctx context.Context, question *proto.Question) (*
 }
 
 func (m *Manager) ChangeQuestionState(ctx context.Context, req *proto.ChangeQuestionStateRequest) (*proto.Empty, error) {
-	userId, _, err := authenticate(ctx)
+	userId, _, err := m.authenticate(ctx)
 	if err != nil {
 		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
@@ -412,11 +500,135 @@ func (m *Manager) ChangeQuestionState(ctx context.Context, req *proto.ChangeQues
 	return &proto.Empty{}, status.Error(codes.OK, "question state changed successfully")
 }
 
+func (m *Manager) GetTestCases(ctx context.Context, req *proto.ID) (*proto.GetTestCasesResponse, error) {
+	questionId, err := strconv.Atoi(req.GetValue())
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "question not found: %v", req.GetValue())
+	}
+	if err := m.authorizeQuestionEditor(ctx, questionId); err != nil {
+		return nil, err
+	}
+	testCases, err := m.db.GetTestCases(ctx, questionId)
+	if err != nil {
+		return nil, getCodeOrInternalError(err)
+	}
+	return &proto.GetTestCasesResponse{TestCases: testCases}, nil
+}
+
+func (m *Manager) CreateTestCase(ctx context.Context, testCase *proto.TestCase) (*proto.ID, error) {
+	questionId, err := strconv.Atoi(testCase.GetQuestionId())
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "question not found: %v", testCase.GetQuestionId())
+	}
+	if err := m.authorizeQuestionEditor(ctx, questionId); err != nil {
+		return nil, err
+	}
+	if err := m.validateTestCaseSubtask(ctx, questionId, testCase); err != nil {
+		return nil, err
+	}
+	testCaseId, err := m.db.CreateTestCase(ctx, questionId, testCase)
+	if err != nil {
+		return nil, getCodeOrInternalError(err)
+	}
+	return &proto.ID{Value: fmt.Sprintf("%d", testCaseId)}, status.Error(codes.OK, "")
+}
+
+func (m *Manager) EditTestCase(ctx context.Context, testCase *proto.TestCase) (*proto.Empty, error) {
+	if testCase.Id == nil {
+		return nil, status.Error(codes.InvalidArgument, "test case id not provided")
+	}
+	testCaseId, questionId, err := m.authorizeTestCaseEditor(ctx, testCase.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if err := m.validateTestCaseSubtask(ctx, questionId, testCase); err != nil {
+		return nil, err
+	}
+	err = m.db.EditTestCase(ctx, testCaseId, testCase)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "test case not found")
+		}
+		return nil, getCodeOrInternalError(err)
+	}
+	return &proto.Empty{}, status.Error(codes.OK, "test case edited successfully")
+}
+
+func (m *Manager) DeleteTestCase(ctx context.Context, req *proto.ID) (*proto.Empty, error) {
+	testCaseId, _, err := m.authorizeTestCaseEditor(ctx, req.GetValue())
+	if err != nil {
+		return nil, err
+	}
+	err = m.db.DeleteTestCase(ctx, testCaseId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "test case not found")
+		}
+		return nil, getCodeOrInternalError(err)
+	}
+	return &proto.Empty{}, status.Error(codes.OK, "test case deleted successfully")
+}
+
+func (m *Manager) CreateSubtask(ctx context.Context, subtask *proto.Subtask) (*proto.ID, error) {
+	questionId, err := strconv.Atoi(subtask.GetQuestionId())
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "question not found: %v", subtask.GetQuestionId())
+	}
+	if err := m.authorizeQuestionEditor(ctx, questionId); err != nil {
+		return nil, err
+	}
+	if subtask.GetPoints() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "subtask points can not be negative")
+	}
+	subtaskId, err := m.db.CreateSubtask(ctx, questionId, subtask)
+	if err != nil {
+		return nil, getCodeOrInternalError(err)
+	}
+	return &proto.ID{Value: fmt.Sprintf("%d", subtaskId)}, status.Error(codes.OK, "")
+}
+
+func (m *Manager) EditSubtask(ctx context.Context, subtask *proto.Subtask) (*proto.Empty, error) {
+	if subtask.Id == nil {
+		return nil, status.Error(codes.InvalidArgument, "subtask id not provided")
+	}
+	subtaskId, err := m.authorizeSubtaskEditor(ctx, subtask.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if subtask.GetPoints() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "subtask points can not be negative")
+	}
+	err = m.db.EditSubtask(ctx, subtaskId, subtask)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "subtask not found")
+		}
+		return nil, getCodeOrInternalError(err)
+	}
+	return &proto.Empty{}, status.Error(codes.OK, "subtask edited successfully")
+}
+
+func (m *Manager) DeleteSubtask(ctx context.Context, req *proto.ID) (*proto.Empty, error) {
+	subtaskId, err := m.authorizeSubtaskEditor(ctx, req.GetValue())
+	if err != nil {
+		return nil, err
+	}
+	err = m.db.DeleteSubtask(ctx, subtaskId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "subtask not found")
+		}
+		return nil, getCodeOrInternalError(err)
+	}
+	return &proto.Empty{}, status.Error(codes.OK, "subtask deleted successfully")
+}
+
 func (m *Manager) UpdateSubmission(ctx context.Context, submission *proto.Submission) (*proto.UpdateSubmissionResponse, error) {
-	_, isJudge, err := authenticate(ctx)
-	if err != nil || !isJudge {
-		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	judge, err := m.authenticateJudge(ctx, submission.GetJudge())
+	if err != nil {
+		return nil, err
 	}
+	submission.Judge = &judge
 	newState := submission.GetState()
 	if newState == proto.SubmissionState_SUBMISSION_STATE_UNKNOWN {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid state: %s", newState)
@@ -426,34 +638,383 @@ func (m *Manager) UpdateSubmission(ctx context.Context, submission *proto.Submis
 	if err != nil {
 		return nil, status.Errorf(codes.NotFound, "submission not found: %v", submissionIdStr)
 	}
-	updated, err := m.db.UpdateSubmissionState(ctx, int32(submissionId), int32(newState))
+	updated, err := m.db.UpdateSubmissionResult(ctx, int32(submissionId), submission)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, status.Errorf(codes.NotFound, "submission not found")
 		}
 		return nil, getCodeOrInternalError(err)
 	}
+	if updated && newState == proto.SubmissionState_SUBMISSION_STATE_PENDING {
+		m.jobs.notify()
+	}
 
 	return &proto.UpdateSubmissionResponse{Updated: updated}, status.Errorf(codes.OK, "")
 }
 
-func authenticate(ctx context.Context) (userId int32, isJudge bool, err error) {
+func (m *Manager) LeaseJobs(ctx context.Context, req *proto.LeaseJobsRequest) (*proto.LeaseJobsResponse, error) {
+	judge, err := m.authenticateJudge(ctx, req.GetJudge())
+	if err != nil {
+		return nil, err
+	}
+	count := int(req.GetCount())
+	if count <= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid count: %v", count)
+	}
+	count = min(count, jobsBatchSize)
+	registered, err := m.checkJudgeEnabled(ctx, judge)
+	if err != nil {
+		return nil, err
+	}
+
+	submissions, leaseExpiresAt, err := m.db.LeaseSubmissions(ctx, judge, registered.GetLanguages(), count)
+	if err != nil {
+		return nil, getCodeOrInternalError(err)
+	}
+	return &proto.LeaseJobsResponse{Submissions: submissions, LeaseExpiresAt: leaseExpiresAt.UnixMilli()},
+		status.Error(codes.OK, "")
+}
+
+func (m *Manager) Heartbeat(ctx context.Context, req *proto.HeartbeatRequest) (*proto.HeartbeatResponse, error) {
+	judge, err := m.authenticateJudge(ctx, req.GetJudge())
+	if err != nil {
+		return nil, err
+	}
+	if err := m.db.RecordJudgeHeartbeat(ctx, judge); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.FailedPrecondition, "judge is not registered")
+		}
+		return nil, getCodeOrInternalError(err)
+	}
+	var submissionIds []int32
+	for _, idStr := range req.GetSubmissionIds() {
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			return nil, status.Errorf(codes.NotFound, "submission not found: %v", idStr)
+		}
+		submissionIds = append(submissionIds, int32(id))
+	}
+	if len(submissionIds) == 0 {
+		return &proto.HeartbeatResponse{}, status.Error(codes.OK, "")
+	}
+
+	renewedIds, leaseExpiresAt, err := m.db.RenewLeases(ctx, judge, submissionIds)
+	if err != nil {
+		return nil, getCodeOrInternalError(err)
+	}
+	renewed := make([]string, 0, len(renewedIds))
+	for _, id := range renewedIds {
+		renewed = append(renewed, strconv.Itoa(int(id)))
+	}
+	return &proto.HeartbeatResponse{Renewed: renewed, LeaseExpiresAt: leaseExpiresAt.UnixMilli()},
+		status.Error(codes.OK, "")
+}
+
+func (m *Manager) RegisterJudge(ctx context.Context, judge *proto.Judge) (*proto.Empty, error) {
+	judgeId, err := m.authenticateJudge(ctx, judge.GetId())
+	if err != nil {
+		return nil, err
+	}
+	judge.Id = judgeId
+	if judge.GetCapacity() <= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid capacity: %v", judge.GetCapacity())
+	}
+	if err := m.db.RegisterJudge(ctx, judge); err != nil {
+		return nil, getCodeOrInternalError(err)
+	}
+	return &proto.Empty{}, status.Error(codes.OK, "judge registered successfully")
+}
+
+func (m *Manager) ListJudges(ctx context.Context, req *proto.Empty) (*proto.ListJudgesResponse, error) {
+	if err := m.authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+	judges, err := m.db.GetJudges(ctx)
+	if err != nil {
+		return nil, getCodeOrInternalError(err)
+	}
+	return &proto.ListJudgesResponse{Judges: judges}, status.Error(codes.OK, "")
+}
+
+func (m *Manager) DisableJudge(ctx context.Context, req *proto.DisableJudgeRequest) (*proto.Empty, error) {
+	if err := m.authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+	err := m.db.SetJudgeDisabled(ctx, req.GetId(), req.GetDisabled())
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "judge not found")
+		}
+		return nil, getCodeOrInternalError(err)
+	}
+	return &proto.Empty{}, status.Error(codes.OK, "")
+}
+
+func (m *Manager) CreateJudgeKey(ctx context.Context, req *proto.JudgeKey) (*proto.JudgeKey, error) {
+	if err := m.authorizeSuperuser(ctx); err != nil {
+		return nil, err
+	}
+	if req.GetJudgeId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "judge id is required")
+	}
+	key, err := internal.GenerateAPIKey()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to generate key")
+	}
+	judgeKey, err := m.db.CreateJudgeKey(ctx, req.GetJudgeId(), internal.HashAPIKey(key))
+	if err != nil {
+		return nil, getCodeOrInternalError(err)
+	}
+	judgeKey.Key = key
+	return judgeKey, status.Error(codes.OK, "key created successfully")
+}
+
+func (m *Manager) RevokeJudgeKey(ctx context.Context, req *proto.ID) (*proto.Empty, error) {
+	if err := m.authorizeSuperuser(ctx); err != nil {
+		return nil, err
+	}
+	keyId, err := strconv.Atoi(req.GetValue())
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "key not found: %v", req.GetValue())
+	}
+	if err := m.db.RevokeJudgeKey(ctx, int32(keyId)); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.NotFound, "key not found")
+		}
+		return nil, getCodeOrInternalError(err)
+	}
+	return &proto.Empty{}, status.Error(codes.OK, "key revoked successfully")
+}
+
+func (m *Manager) GetJudgeKeys(ctx context.Context, req *proto.Empty) (*proto.GetJudgeKeysResponse, error) {
+	if err := m.authorizeSuperuser(ctx); err != nil {
+		return nil, err
+	}
+	keys, err := m.db.GetJudgeKeys(ctx)
+	if err != nil {
+		return nil, getCodeOrInternalError(err)
+	}
+	return &proto.GetJudgeKeysResponse{Keys: keys}, status.Error(codes.OK, "")
+}
+
+func (m *Manager) StreamJobs(req *proto.StreamJobsRequest, stream proto.Manager_StreamJobsServer) error {
+	ctx := stream.Context()
+	judge, err := m.authenticateJudge(ctx, "")
+	if err != nil {
+		return err
+	}
+	// a disabled judge could lease nothing it is sent, it is refused until an admin enables it again
+	if _, err := m.checkJudgeEnabled(ctx, judge); err != nil {
+		return err
+	}
+	wakeup := m.jobs.subscribe()
+	defer m.jobs.unsubscribe(wakeup)
+	// submissions made pending by another manager instance are not notified, so look again periodically
+	ticker := time.NewTicker(jobsResyncInterval)
+	defer ticker.Stop()
+
+	sent := make(map[string]bool)
+	for {
+		sent, err = m.sendPendingSubmissions(ctx, stream, sent)
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wakeup:
+		case <-ticker.C:
+		}
+	}
+}
+
+// authorizeAdmin checks that the caller is an admin or a superuser.
+func (m *Manager) authorizeAdmin(ctx context.Context) error {
+	role, err := m.callerRole(ctx)
+	if err != nil {
+		return err
+	}
+	if !isAdmin(role) {
+		return status.Error(codes.PermissionDenied, "only admins can manage judges")
+	}
+	return nil
+}
+
+func (m *Manager) authorizeSuperuser(ctx context.Context) error {
+	role, err := m.callerRole(ctx)
+	if err != nil {
+		return err
+	}
+	if role != proto.Role_ROLE_SUPERUSER {
+		return status.Error(codes.PermissionDenied, "only superusers can manage judge keys")
+	}
+	return nil
+}
+
+// callerRole returns the role of the calling user, judges have none.
+func (m *Manager) callerRole(ctx context.Context) (proto.Role, error) {
+	userId, isJudge, err := m.authenticate(ctx)
+	if err != nil {
+		return proto.Role_ROLE_UNKNOWN, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if isJudge {
+		return proto.Role_ROLE_UNKNOWN, nil
+	}
+	_, role, err := m.db.GetUserRole(ctx, userId)
+	if err != nil {
+		return proto.Role_ROLE_UNKNOWN, getCodeOrInternalError(err)
+	}
+	return role, nil
+}
+
+// authorizeQuestionEditor checks that the caller owns the question or is an admin.
+func (m *Manager) authorizeQuestionEditor(ctx context.Context, questionId int) error {
+	userId, _, err := m.authenticate(ctx)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	username, role, err := m.db.GetUserRole(ctx, userId)
+	if err != nil {
+		return getCodeOrInternalError(err)
+	}
+	question, err := m.db.GetQuestion(ctx, questionId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return status.Error(codes.NotFound, "question not found")
+		}
+		return getCodeOrInternalError(err)
+	}
+	if !isAdmin(role) && question.GetOwner() != username {
+		return status.Error(codes.PermissionDenied, "you do not have access to this question")
+	}
+	return nil
+}
+
+// authorizeTestCaseEditor resolves the test case id and its question, and checks access to the question.
+func (m *Manager) authorizeTestCaseEditor(ctx context.Context, testCaseIdStr string) (int, int, error) {
+	testCaseId, err := strconv.Atoi(testCaseIdStr)
+	if err != nil {
+		return 0, 0, status.Errorf(codes.NotFound, "test case not found: %v", testCaseIdStr)
+	}
+	questionId, err := m.db.GetTestCaseQuestion(ctx, testCaseId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, 0, status.Error(codes.NotFound, "test case not found")
+		}
+		return 0, 0, getCodeOrInternalError(err)
+	}
+	return testCaseId, questionId, m.authorizeQuestionEditor(ctx, questionId)
+}
+
+// authorizeSubtaskEditor resolves the subtask id and checks access to its question.
+func (m *Manager) authorizeSubtaskEditor(ctx context.Context, subtaskIdStr string) (int, error) {
+	subtaskId, err := strconv.Atoi(subtaskIdStr)
+	if err != nil {
+		return 0, status.Errorf(codes.NotFound, "subtask not found: %v", subtaskIdStr)
+	}
+	questionId, err := m.db.GetSubtaskQuestion(ctx, subtaskId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, status.Error(codes.NotFound, "subtask not found")
+		}
+		return 0, getCodeOrInternalError(err)
+	}
+	return subtaskId, m.authorizeQuestionEditor(ctx, questionId)
+}
+
+// validateTestCaseSubtask checks that the subtask of the test case, if any, belongs to the same question.
+func (m *Manager) validateTestCaseSubtask(ctx context.Context, questionId int, testCase *proto.TestCase) error {
+	if testCase.SubtaskId == nil {
+		return nil
+	}
+	subtaskId, err := strconv.Atoi(testCase.GetSubtaskId())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid subtask: %v", testCase.GetSubtaskId())
+	}
+	subtaskQuestionId, err := m.db.GetSubtaskQuestion(ctx, subtaskId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return status.Error(codes.InvalidArgument, "subtask not found")
+		}
+		return getCodeOrInternalError(err)
+	}
+	if subtaskQuestionId != questionId {
+		return status.Error(codes.InvalidArgument, "subtask belongs to another question")
+	}
+	return nil
+}
+
+func (m *Manager) authenticate(ctx context.Context) (userId int32, isJudge bool, err error) {
+	userId, judgeId, err := m.authenticateCaller(ctx)
+	return userId, judgeId != "", err
+}
+
+// authenticateCaller returns the user of a JWT, or the judge a "Token" API key was issued to.
+func (m *Manager) authenticateCaller(ctx context.Context) (userId int32, judgeId string, err error) {
 	tokenType, token, err := internal.ExtractTokenFromContext(ctx)
 	if err != nil {
-		return 0, false, err
+		return 0, "", err
 	}
 	if tokenType == "Bearer" {
 		userId, _, err := internal.ValidateJWT(token)
 		if err != nil {
-			return 0, false, err
+			return 0, "", err
 		}
-		return userId, false, nil
+		return userId, "", nil
 
 	} else if tokenType == "Token" {
-		isJudge := internal.IsJudgeServer(token)
-		return 0, isJudge, nil
+		if m.requireJudgeCertificates && !hasVerifiedCertificate(ctx) {
+			return 0, "", errors.New("judges must present a client certificate")
+		}
+		judgeId, err := m.db.GetJudgeKeyOwner(ctx, internal.HashAPIKey(token))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return 0, "", errors.New("invalid or revoked api key")
+			}
+			return 0, "", err
+		}
+		return 0, judgeId, nil
+	}
+	return 0, "", nil
+}
+
+// hasVerifiedCertificate tells whether the caller presented a client certificate verified against the client CA.
+func hasVerifiedCertificate(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	return ok && len(info.State.VerifiedChains) > 0
+}
+
+// authenticateJudge returns the judge the API key of the caller was issued to. A judge can only act
+// under its own id, claimedId may be empty to use it implicitly.
+func (m *Manager) authenticateJudge(ctx context.Context, claimedId string) (string, error) {
+	_, judgeId, err := m.authenticateCaller(ctx)
+	if err != nil || judgeId == "" {
+		return "", status.Error(codes.Unauthenticated, "invalid token")
+	}
+	if claimedId != "" && claimedId != judgeId {
+		return "", status.Errorf(codes.PermissionDenied, "the api key was issued to judge %v", judgeId)
+	}
+	return judgeId, nil
+}
+
+// checkJudgeEnabled returns the registration of the judge, failing with FailedPrecondition unless the
+// judge is registered and not disabled.
+func (m *Manager) checkJudgeEnabled(ctx context.Context, judge string) (*proto.Judge, error) {
+	registered, err := m.db.GetJudge(ctx, judge)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Error(codes.FailedPrecondition, "judge is not registered")
+		}
+		return nil, getCodeOrInternalError(err)
 	}
-	return 0, false, nil
+	if registered.GetStatus() == proto.JudgeStatus_JUDGE_STATUS_DISABLED {
+		return nil, status.Error(codes.FailedPrecondition, "judge is disabled")
+	}
+	return registered, nil
 }
 
 func getFiltersMap(filters []*proto.Filter) map[string]string {
@@ -468,6 +1029,35 @@ func isAdmin(role proto.Role) bool {
 	return role == proto.Role_ROLE_ADMIN || role == proto.Role_ROLE_SUPERUSER
 }
 
+func (m *Manager) validateQuestion(ctx context.Context, question *proto.Question) error {
+	if checkerLanguage := question.GetCheckerLanguage(); checkerLanguage != "" {
+		if err := m.checkSupportedLanguage(ctx, "checker language", checkerLanguage); err != nil {
+			return err
+		}
+	}
+	if interactorLanguage := question.GetInteractorLanguage(); interactorLanguage != "" {
+		if err := m.checkSupportedLanguage(ctx, "interactor language", interactorLanguage); err != nil {
+			return err
+		}
+	}
+	if question.GetAbsoluteEpsilon() < 0 || question.GetRelativeEpsilon() < 0 {
+		return status.Error(codes.InvalidArgument, "epsilon must not be negative")
+	}
+	return nil
+}
+
+// checkSupportedLanguage fails with InvalidArgument unless a judge that is not disabled registered the language.
+func (m *Manager) checkSupportedLanguage(ctx context.Context, kind, language string) error {
+	languages, err := m.db.GetJudgeLanguages(ctx)
+	if err != nil {
+		return getCodeOrInternalError(err)
+	}
+	if !slices.Contains(languages, language) {
+		return status.Errorf(codes.InvalidArgument, "unsupported %s: %v", kind, language)
+	}
+	return nil
+}
+
 func getCodeOrInternalError(err error) error {
 	code := status.Code(err)
 	if code != codes.Unknown {
@@ -2,6 +2,9 @@ package internal
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/CT1403-2/Code-Judgement/proto"
@@ -91,6 +94,20 @@ func ExtractTokenFromContext(ctx context.Context) (string, string, error) {
 	return parts[0], parts[1], nil
 }
 
-func IsJudgeServer(token string) bool {
-	return token == GetEnv("JUDGE_API_SECRET_KEY", "")
+const apiKeyLength = 32 // bytes
+
+// GenerateAPIKey returns a random key for a judge, hex encoded.
+func GenerateAPIKey() (string, error) {
+	key := make([]byte, apiKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// HashAPIKey hashes a judge key for storage and lookup. Keys are random, so unlike passwords they
+// need no salt or slow hash.
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
 }